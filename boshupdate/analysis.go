@@ -0,0 +1,295 @@
+package boshupdate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	"github.com/cppforlife/go-patch/patch"
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// InferOps - Finds upstream ops-files that best explain bosh releases of given deployment
+//
+// Canonical manifest and ops-files are taken at the version currently deployed. Candidates
+// are greedily added to the selection as long as they bring rendered releases closer to
+// deployed ones. Results are cached by deployment, version and deployed releases.
+func (a *Manager) InferOps(deployment BoshDeploymentData, item ManifestReleaseData) (*OpsAnalysis, error) {
+	entry := log.WithFields(log.Fields{
+		"deployment": deployment.Deployment,
+		"name":       item.Name,
+		"version":    deployment.Ref,
+	})
+
+	if a.snapshot != nil {
+		return nil, fmt.Errorf("ops-files inference is not available from snapshot")
+	}
+	if len(item.Manifest) == 0 || len(item.OpsDir) == 0 {
+		return nil, fmt.Errorf("manifest release '%s' requires both manifest and ops_dir", item.Name)
+	}
+
+	var version *Version
+	for idx := range item.Versions {
+		if item.Versions[idx].Version == deployment.Ref {
+			version = &item.Versions[idx]
+			break
+		}
+	}
+	if version == nil {
+		return nil, fmt.Errorf("unable to find version '%s' of manifest release '%s'", deployment.Ref, item.Name)
+	}
+
+	key := fmt.Sprintf("%s@%s|%s", deployment.Deployment, version.GitRef, releasesKey(deployment.BoshReleases))
+	a.mutex.Lock()
+	cached, found := a.opsCache[key]
+	a.mutex.Unlock()
+	if found {
+		entry.Debugf("using cached ops-file analysis")
+		return cached, nil
+	}
+
+	entry.Debugf("downloading manifest")
+	content, err := a.getContent(version.GitRef, item.ManifestReleaseConfig, item.Manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	varList := []boshtpl.Variables{}
+	for _, varPath := range item.Vars {
+		vars, err := a.getVarsFile(version.GitRef, item.ManifestReleaseConfig, varPath)
+		if err != nil {
+			return nil, err
+		}
+		varList = append(varList, vars)
+	}
+	vars := boshtpl.NewMultiVars(varList)
+
+	entry.Debugf("listing ops-files in '%s'", item.OpsDir)
+	paths, err := a.listFiles(version.GitRef, item.ManifestReleaseConfig, item.OpsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := map[string]patch.Ops{}
+	for p, url := range paths {
+		val, err := a.download(url)
+		if err != nil {
+			return nil, err
+		}
+		ops, err := parseOpsFile(val, p)
+		if err != nil {
+			entry.Debugf("ignoring ops-file candidate: %s", err)
+			continue
+		}
+		candidates[p] = ops
+	}
+
+	selected, current, err := selectOps(boshtpl.NewTemplate(content), vars, candidates, deployment.BoshReleases)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to render manifest '%s'", item.Manifest)
+	}
+	for _, p := range selected {
+		entry.Debugf("selected ops-file '%s'", p)
+	}
+
+	res := &OpsAnalysis{
+		Deployment:   deployment.Deployment,
+		ManifestName: item.Name,
+		Version:      deployment.Ref,
+		Ops:          selected,
+		Matched:      []string{},
+		Missing:      []string{},
+		Extra:        []string{},
+	}
+	rendered := map[string]bool{}
+	for _, r := range current {
+		rendered[r.Name] = true
+	}
+	deployed := map[string]bool{}
+	for _, r := range deployment.BoshReleases {
+		deployed[r.Name] = true
+		if rendered[r.Name] {
+			res.Matched = append(res.Matched, r.Name)
+		} else {
+			res.Missing = append(res.Missing, r.Name)
+		}
+	}
+	for _, r := range current {
+		if !deployed[r.Name] {
+			res.Extra = append(res.Extra, r.Name)
+		}
+	}
+
+	a.mutex.Lock()
+	a.opsCache[key] = res
+	a.mutex.Unlock()
+	return res, nil
+}
+
+// listFiles - Recursively lists download urls of yaml files in given remote directory
+//
+// Files are fetched from their download url which does not consume API rate limit.
+// Listings are cached by repository, ref and directory.
+func (a *Manager) listFiles(ref string, item ManifestReleaseConfig, dir string) (map[string]string, error) {
+	key := fmt.Sprintf("%s|%s", repoRefKey(item.GenericReleaseConfig, ref), dir)
+	a.mutex.Lock()
+	cached, found := a.listCache[key]
+	a.mutex.Unlock()
+	if found {
+		return cached, nil
+	}
+
+	opts := github.RepositoryContentGetOptions{Ref: ref}
+	_, entries, _, err := a.client.Repositories.GetContents(a.ctx, item.Owner, item.Repo, dir, &opts)
+	if err != nil {
+		return nil, errors.Wrapf(checkRateLimit(err), "could not list directory '%s'", dir)
+	}
+
+	res := map[string]string{}
+	for _, e := range entries {
+		switch {
+		case e.GetType() == "dir":
+			files, err := a.listFiles(ref, item, e.GetPath())
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range files {
+				res[k] = v
+			}
+		case strings.HasSuffix(e.GetName(), ".yml") || strings.HasSuffix(e.GetName(), ".yaml"):
+			res[e.GetPath()] = e.GetDownloadURL()
+		}
+	}
+
+	a.mutex.Lock()
+	a.listCache[key] = res
+	a.mutex.Unlock()
+	return res, nil
+}
+
+// availableOps - Keeps inferred ops-files that still exist at latest version of manifest release
+//
+// Ops-files are inferred at the version currently deployed, some may have been removed or
+// renamed since.
+func (a *Manager) availableOps(item ManifestReleaseData, ops []string) ([]string, error) {
+	files, err := a.listFiles(item.LatestVersion.GitRef, item.ManifestReleaseConfig, item.OpsDir)
+	if err != nil {
+		return nil, err
+	}
+	res := []string{}
+	for _, p := range ops {
+		if _, found := files[p]; !found {
+			log.Warnf("ignoring ops-file '%s' missing from version '%s' of manifest release '%s'",
+				p, item.LatestVersion.Version, item.Name)
+			continue
+		}
+		res = append(res, p)
+	}
+	return res, nil
+}
+
+// selectOps - Greedily selects candidate ops-files bringing rendered releases closer to deployed ones
+//
+// At each step, candidate giving the best score strictly above current one is selected, ties
+// going to the first candidate in path order. Candidates failing to apply are retried on top of
+// next selections, candidates leaving rendered releases unchanged are dropped. Gives selected
+// paths in order and releases rendered with them.
+func selectOps(tpl boshtpl.Template, vars boshtpl.Variables, candidates map[string]patch.Ops, deployed []BoshRelease) ([]string, []BoshRelease, error) {
+	selected := []string{}
+	selectedOps := patch.Ops{}
+	current, err := renderReleases(tpl, vars, selectedOps)
+	if err != nil {
+		return nil, nil, err
+	}
+	score := scoreReleases(current, deployed)
+
+	for {
+		best := ""
+		bestScore := score
+		var bestReleases []BoshRelease
+		for _, p := range sortedKeys(candidates) {
+			releases, err := renderReleases(tpl, vars, append(selectedOps, candidates[p]))
+			if err != nil {
+				// may apply later on top of another ops-file
+				continue
+			}
+			if releasesKey(releases) == releasesKey(current) {
+				delete(candidates, p)
+				continue
+			}
+			if s := scoreReleases(releases, deployed); s > bestScore {
+				best, bestScore, bestReleases = p, s, releases
+			}
+		}
+		if best == "" {
+			break
+		}
+		selected = append(selected, best)
+		selectedOps = append(selectedOps, candidates[best])
+		delete(candidates, best)
+		current, score = bestReleases, bestScore
+	}
+	return selected, current, nil
+}
+
+func renderReleases(tpl boshtpl.Template, vars boshtpl.Variables, ops patch.Ops) ([]BoshRelease, error) {
+	final, err := tpl.Evaluate(vars, ops, boshtpl.EvaluateOpts{})
+	if err != nil {
+		return nil, err
+	}
+	var manifest BoshManifest
+	if err = yaml.Unmarshal(final, &manifest); err != nil {
+		return nil, err
+	}
+	return manifest.Releases, nil
+}
+
+// scoreReleases - Rates how close rendered releases are from deployed ones
+//
+// Each deployed release found in rendered ones scores 2, or 3 on exact version
+// match. Each rendered release not deployed costs 2.
+func scoreReleases(rendered []BoshRelease, deployed []BoshRelease) int {
+	versions := map[string]string{}
+	for _, r := range deployed {
+		versions[r.Name] = r.Version
+	}
+	score := 0
+	for _, r := range rendered {
+		v, found := versions[r.Name]
+		switch {
+		case !found:
+			score -= 2
+		case v == r.Version:
+			score += 3
+		default:
+			score += 2
+		}
+	}
+	return score
+}
+
+func releasesKey(releases []BoshRelease) string {
+	keys := []string{}
+	for _, r := range releases {
+		keys = append(keys, r.Name+"/"+r.Version)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+func sortedKeys(m map[string]patch.Ops) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Local Variables:
+// ispell-local-dictionary: "american"
+// End:
@@ -0,0 +1,196 @@
+package boshupdate
+
+import (
+	"reflect"
+	"testing"
+
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	"github.com/cppforlife/go-patch/patch"
+)
+
+func TestScoreReleases(t *testing.T) {
+	tests := []struct {
+		name     string
+		rendered []BoshRelease
+		deployed []BoshRelease
+		expected int
+	}{
+		{
+			name:     "exact versions",
+			rendered: []BoshRelease{{Name: "capi", Version: "1.0"}, {Name: "diego", Version: "2.0"}},
+			deployed: []BoshRelease{{Name: "diego", Version: "2.0"}, {Name: "capi", Version: "1.0"}},
+			expected: 6,
+		},
+		{
+			name:     "different version",
+			rendered: []BoshRelease{{Name: "capi", Version: "1.0"}},
+			deployed: []BoshRelease{{Name: "capi", Version: "1.1"}},
+			expected: 2,
+		},
+		{
+			name:     "rendered release not deployed",
+			rendered: []BoshRelease{{Name: "capi", Version: "1.0"}, {Name: "haproxy", Version: "9.0"}},
+			deployed: []BoshRelease{{Name: "capi", Version: "1.0"}},
+			expected: 1,
+		},
+		{
+			name:     "deployed release not rendered",
+			rendered: []BoshRelease{{Name: "capi", Version: "1.0"}},
+			deployed: []BoshRelease{{Name: "capi", Version: "1.0"}, {Name: "custom", Version: "0.1"}},
+			expected: 3,
+		},
+		{
+			name:     "empty",
+			rendered: []BoshRelease{},
+			deployed: []BoshRelease{},
+			expected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		if res := scoreReleases(tt.rendered, tt.deployed); res != tt.expected {
+			t.Errorf("%s: expected score %d, got %d", tt.name, tt.expected, res)
+		}
+	}
+}
+
+func TestSelectOps(t *testing.T) {
+	manifest := []byte(`
+name: cf
+releases:
+- name: capi
+  version: "1.0"
+- name: diego
+  version: "2.0"
+`)
+	opsFiles := map[string]string{
+		"add-haproxy.yml": `
+- type: replace
+  path: /releases/-
+  value: {name: haproxy, version: "9.0"}
+`,
+		"add-routing.yml": `
+- type: replace
+  path: /releases/-
+  value: {name: routing, version: "0.1"}
+`,
+		"bump-routing.yml": `
+- type: replace
+  path: /releases/name=routing/version
+  value: "0.2"
+`,
+		"use-routing.yml": `
+- type: replace
+  path: /releases/name=routing?
+  value: {name: routing, version: "0.1"}
+`,
+		"same-capi.yml": `
+- type: replace
+  path: /releases/name=capi/version
+  value: "1.0"
+`,
+	}
+	deployed := []BoshRelease{
+		{Name: "capi", Version: "1.0"},
+		{Name: "diego", Version: "2.0"},
+		{Name: "routing", Version: "0.2"},
+	}
+	base := []BoshRelease{
+		{Name: "capi", Version: "1.0"},
+		{Name: "diego", Version: "2.0"},
+	}
+
+	tests := []struct {
+		name             string
+		manifest         []byte
+		candidates       []string
+		deployed         []BoshRelease
+		expectedOps      []string
+		expectedReleases []BoshRelease
+		expectedError    bool
+	}{
+		{
+			name:             "no candidates",
+			manifest:         manifest,
+			candidates:       []string{},
+			deployed:         deployed,
+			expectedOps:      []string{},
+			expectedReleases: base,
+		},
+		{
+			name:             "no match",
+			manifest:         manifest,
+			candidates:       []string{"add-haproxy.yml", "same-capi.yml"},
+			deployed:         deployed,
+			expectedOps:      []string{},
+			expectedReleases: base,
+		},
+		{
+			name:             "nothing deployed",
+			manifest:         manifest,
+			candidates:       []string{"add-haproxy.yml", "add-routing.yml"},
+			deployed:         []BoshRelease{},
+			expectedOps:      []string{},
+			expectedReleases: base,
+		},
+		{
+			name:        "candidate applying on top of selected one",
+			manifest:    manifest,
+			candidates:  []string{"add-haproxy.yml", "add-routing.yml", "bump-routing.yml", "same-capi.yml"},
+			deployed:    deployed,
+			expectedOps: []string{"add-routing.yml", "bump-routing.yml"},
+			expectedReleases: []BoshRelease{
+				{Name: "capi", Version: "1.0"},
+				{Name: "diego", Version: "2.0"},
+				{Name: "routing", Version: "0.2"},
+			},
+		},
+		{
+			name:        "equal scores",
+			manifest:    manifest,
+			candidates:  []string{"use-routing.yml", "add-routing.yml"},
+			deployed:    deployed,
+			expectedOps: []string{"add-routing.yml"},
+			expectedReleases: []BoshRelease{
+				{Name: "capi", Version: "1.0"},
+				{Name: "diego", Version: "2.0"},
+				{Name: "routing", Version: "0.1"},
+			},
+		},
+		{
+			name:          "invalid manifest",
+			manifest:      []byte("releases: ["),
+			candidates:    []string{"add-routing.yml"},
+			deployed:      deployed,
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		candidates := map[string]patch.Ops{}
+		for _, p := range tt.candidates {
+			ops, err := parseOpsFile([]byte(opsFiles[p]), p)
+			if err != nil {
+				t.Fatalf("%s: unable to parse ops-file '%s': %s", tt.name, p, err)
+			}
+			candidates[p] = ops
+		}
+		ops, releases, err := selectOps(boshtpl.NewTemplate(tt.manifest), boshtpl.StaticVariables{}, candidates, tt.deployed)
+		if tt.expectedError {
+			if err == nil {
+				t.Errorf("%s: expected error, got nil", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(ops, tt.expectedOps) {
+			t.Errorf("%s: expected ops %v, got %v", tt.name, tt.expectedOps, ops)
+		}
+		if !reflect.DeepEqual(releases, tt.expectedReleases) {
+			t.Errorf("%s: expected releases %+v, got %+v", tt.name, tt.expectedReleases, releases)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package boshupdate
+
+import (
+	"sort"
+	"time"
+
+	semver "github.com/cppforlife/go-semi-semantic/version"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// AssetRelease - Bosh release uploaded to the director
+	AssetRelease = "release"
+	// AssetStemcell - Stemcell uploaded to the director
+	AssetStemcell = "stemcell"
+
+	// bosh clean-up keeps this number of most recent versions of unused assets
+	cleanUpKeptVersions = 2
+)
+
+type asset struct {
+	data    AssetData
+	version semver.Version
+}
+
+// GetAssets - Lists releases and stemcells uploaded to the director with their usage
+//
+// Unused assets are flagged as 'bosh clean-up' would remove them, see cleanUpAssets.
+func (a *Manager) GetAssets() ([]AssetData, error) {
+	entry := log.WithField("name", "assets")
+	entry.Debugf("processing director releases and stemcells")
+	if a.snapshot != nil {
+		return a.snapshot.Assets, nil
+	}
+
+	deployments, err := a.director.ListDeployments()
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to fetch deployments")
+	}
+	usage := map[string][]string{}
+	for _, d := range deployments {
+		for _, r := range d.Releases {
+			key := assetKey(AssetRelease, r.Name, r.Version)
+			usage[key] = append(usage[key], d.Name)
+		}
+		for _, s := range d.Stemcells {
+			key := assetKey(AssetStemcell, s.Name, s.Version)
+			usage[key] = append(usage[key], d.Name)
+		}
+	}
+
+	assets := []asset{}
+	releases, err := a.director.Releases()
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to fetch releases")
+	}
+	for _, r := range releases {
+		for _, v := range r.Versions {
+			as, err := newAsset(AssetRelease, r.Name, "", v.Version)
+			if err != nil {
+				return nil, err
+			}
+			assets = append(assets, as)
+		}
+	}
+	stemcells, err := a.director.Stemcells()
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to fetch stemcells")
+	}
+	for _, s := range stemcells {
+		as, err := newAsset(AssetStemcell, s.Name, s.OperatingSystem, s.Version)
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, as)
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return cleanUpAssets(sortAssets(assets), usage, a.unusedSince, time.Now().Unix()), nil
+}
+
+// sortAssets - Sorts assets by type and name, most recent versions first
+func sortAssets(assets []asset) []asset {
+	sort.SliceStable(assets, func(i, j int) bool {
+		if assets[i].data.Type != assets[j].data.Type {
+			return assets[i].data.Type < assets[j].data.Type
+		}
+		if assets[i].data.Name != assets[j].data.Name {
+			return assets[i].data.Name < assets[j].data.Name
+		}
+		return assets[i].version.Compare(assets[j].version) > 0
+	})
+	return assets
+}
+
+// cleanUpAssets - Sets usage of sorted assets and simulates which unused ones bosh clean-up removes
+//
+//  1. The director doesn't tell when an asset stopped being used, UnusedSince gives
+//     the first time the manager observed it unused. Given unusedSince records it by
+//     asset key, newly unused assets get now and used or deleted ones are forgotten.
+//  2. As 'bosh clean-up', the most recent versions of each asset are kept unless
+//     cleaning all.
+func cleanUpAssets(assets []asset, usage map[string][]string, unusedSince map[string]int64, now int64) []AssetData {
+	res := []AssetData{}
+	seen := map[string]bool{}
+	kept := map[string]int{}
+	for _, as := range assets {
+		data := as.data
+		key := assetKey(data.Type, data.Name, data.Version)
+		seen[key] = true
+		if used, found := usage[key]; found {
+			data.Deployments = used
+			delete(unusedSince, key)
+			res = append(res, data)
+			continue
+		}
+		// 1.
+		if _, found := unusedSince[key]; !found {
+			unusedSince[key] = now
+		}
+		data.UnusedSince = unusedSince[key]
+		// 2.
+		kept[data.Type+"/"+data.Name]++
+		data.CleanUpAll = true
+		data.CleanUp = kept[data.Type+"/"+data.Name] > cleanUpKeptVersions
+		res = append(res, data)
+	}
+
+	for key := range unusedSince {
+		if !seen[key] {
+			delete(unusedSince, key)
+		}
+	}
+	return res
+}
+
+func newAsset(kind string, name string, os string, value string) (asset, error) {
+	version, err := semver.NewVersionFromString(value)
+	if err != nil {
+		return asset{}, errors.Wrapf(err, "invalid version of %s '%s/%s'", kind, name, value)
+	}
+	return asset{
+		data: AssetData{
+			Type:        kind,
+			Name:        name,
+			OS:          os,
+			Version:     version.AsString(),
+			Deployments: []string{},
+		},
+		version: version,
+	}, nil
+}
+
+func assetKey(kind string, name string, version string) string {
+	return kind + "/" + name + "/" + version
+}
+
+// Local Variables:
+// ispell-local-dictionary: "american"
+// End:
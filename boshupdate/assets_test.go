@@ -0,0 +1,173 @@
+package boshupdate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewAsset(t *testing.T) {
+	tests := []struct {
+		name          string
+		value         string
+		expected      string
+		expectedError bool
+	}{
+		{name: "release version", value: "1.12.0", expected: "1.12.0"},
+		{name: "stemcell version", value: "621.94", expected: "621.94"},
+		{name: "pre-release", value: "1.0.0-rc.1", expected: "1.0.0-rc.1"},
+		{name: "empty", value: "", expectedError: true},
+		{name: "invalid", value: "1..2", expectedError: true},
+	}
+
+	for _, tt := range tests {
+		res, err := newAsset(AssetRelease, "capi", "", tt.value)
+		if tt.expectedError {
+			if err == nil {
+				t.Errorf("%s: expected error, got nil", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.name, err)
+			continue
+		}
+		if res.data.Version != tt.expected {
+			t.Errorf("%s: expected version %s, got %s", tt.name, tt.expected, res.data.Version)
+		}
+	}
+}
+
+func TestSortAssets(t *testing.T) {
+	tests := []struct {
+		name     string
+		assets   [][3]string
+		expected []string
+	}{
+		{
+			name:     "empty",
+			assets:   [][3]string{},
+			expected: []string{},
+		},
+		{
+			name: "semver order",
+			assets: [][3]string{
+				{AssetRelease, "capi", "1.9.0"},
+				{AssetRelease, "capi", "1.10.0"},
+				{AssetRelease, "capi", "1.10.0-rc.1"},
+				{AssetRelease, "capi", "1.2.0"},
+			},
+			expected: []string{
+				"release/capi/1.10.0",
+				"release/capi/1.10.0-rc.1",
+				"release/capi/1.9.0",
+				"release/capi/1.2.0",
+			},
+		},
+		{
+			name: "type and name first",
+			assets: [][3]string{
+				{AssetStemcell, "bosh-stemcell", "621.94"},
+				{AssetRelease, "diego", "2.0"},
+				{AssetRelease, "capi", "1.0"},
+				{AssetStemcell, "bosh-stemcell", "1.12"},
+				{AssetRelease, "capi", "1.1"},
+			},
+			expected: []string{
+				"release/capi/1.1",
+				"release/capi/1.0",
+				"release/diego/2.0",
+				"stemcell/bosh-stemcell/621.94",
+				"stemcell/bosh-stemcell/1.12",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		assets := []asset{}
+		for _, a := range tt.assets {
+			as, err := newAsset(a[0], a[1], "", a[2])
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %s", tt.name, err)
+			}
+			assets = append(assets, as)
+		}
+		res := []string{}
+		for _, as := range sortAssets(assets) {
+			res = append(res, assetKey(as.data.Type, as.data.Name, as.data.Version))
+		}
+		if !reflect.DeepEqual(res, tt.expected) {
+			t.Errorf("%s: expected order %v, got %v", tt.name, tt.expected, res)
+		}
+	}
+}
+
+func TestCleanUpAssets(t *testing.T) {
+	now := int64(2000)
+
+	tests := []struct {
+		name                string
+		versions            []string
+		usage               map[string][]string
+		unusedSince         map[string]int64
+		expected            []AssetData
+		expectedUnusedSince map[string]int64
+	}{
+		{
+			name:                "empty",
+			versions:            []string{},
+			usage:               map[string][]string{},
+			unusedSince:         map[string]int64{"release/capi/0.9": 1000},
+			expected:            []AssetData{},
+			expectedUnusedSince: map[string]int64{},
+		},
+		{
+			name:     "used",
+			versions: []string{"1.2", "1.1"},
+			usage: map[string][]string{
+				"release/capi/1.2": {"cf"},
+				"release/capi/1.1": {"cf-2", "cf-3"},
+			},
+			unusedSince: map[string]int64{"release/capi/1.1": 1000},
+			expected: []AssetData{
+				{Type: AssetRelease, Name: "capi", Version: "1.2", Deployments: []string{"cf"}},
+				{Type: AssetRelease, Name: "capi", Version: "1.1", Deployments: []string{"cf-2", "cf-3"}},
+			},
+			expectedUnusedSince: map[string]int64{},
+		},
+		{
+			name:        "most recent unused versions kept",
+			versions:    []string{"1.4", "1.3", "1.2", "1.1"},
+			usage:       map[string][]string{"release/capi/1.3": {"cf"}},
+			unusedSince: map[string]int64{"release/capi/1.2": 1000},
+			expected: []AssetData{
+				{Type: AssetRelease, Name: "capi", Version: "1.4", Deployments: []string{}, UnusedSince: now, CleanUpAll: true},
+				{Type: AssetRelease, Name: "capi", Version: "1.3", Deployments: []string{"cf"}},
+				{Type: AssetRelease, Name: "capi", Version: "1.2", Deployments: []string{}, UnusedSince: 1000, CleanUpAll: true},
+				{Type: AssetRelease, Name: "capi", Version: "1.1", Deployments: []string{}, UnusedSince: now, CleanUp: true, CleanUpAll: true},
+			},
+			expectedUnusedSince: map[string]int64{
+				"release/capi/1.4": now,
+				"release/capi/1.2": 1000,
+				"release/capi/1.1": now,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		assets := []asset{}
+		for _, v := range tt.versions {
+			as, err := newAsset(AssetRelease, "capi", "", v)
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %s", tt.name, err)
+			}
+			assets = append(assets, as)
+		}
+		res := cleanUpAssets(assets, tt.usage, tt.unusedSince, now)
+		if !reflect.DeepEqual(res, tt.expected) {
+			t.Errorf("%s: unexpected assets:\n got: %+v\nwant: %+v", tt.name, res, tt.expected)
+		}
+		if !reflect.DeepEqual(tt.unusedSince, tt.expectedUnusedSince) {
+			t.Errorf("%s: expected unused since %v, got %v", tt.name, tt.expectedUnusedSince, tt.unusedSince)
+		}
+	}
+}
@@ -4,26 +4,285 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/cloudfoundry/bosh-cli/director"
 	"github.com/cloudfoundry/bosh-cli/uaa"
+	"github.com/cloudfoundry/bosh-utils/httpclient"
 	"github.com/cloudfoundry/bosh-utils/logger"
+	"github.com/cppforlife/go-patch/patch"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
 )
 
+// VersionExtractorConfig - Tells where to find manifest name and version in deployment manifests
+type VersionExtractorConfig struct {
+	Matchers    []string   `yaml:"matchers"`
+	NamePath    string     `yaml:"name_path"`
+	VersionPath string     `yaml:"version_path"`
+	Format      *Formatter `yaml:"format"`
+}
+
+// NewVersionExtractorConfig - Creates extractor reading manifest_name and manifest_version keys
+func NewVersionExtractorConfig() VersionExtractorConfig {
+	return VersionExtractorConfig{
+		NamePath:    "/manifest_name",
+		VersionPath: "/manifest_version",
+		Format: &Formatter{
+			Match:   "v(.*)",
+			Replace: "${1}",
+		},
+	}
+}
+
+func (c *VersionExtractorConfig) validate() error {
+	defaults := NewVersionExtractorConfig()
+	if len(c.Matchers) == 0 {
+		return fmt.Errorf("missing mandatory matchers")
+	}
+	for _, m := range c.Matchers {
+		if _, err := regexp.Compile(m); err != nil {
+			return fmt.Errorf("invalid match regexp '%s'", m)
+		}
+	}
+	if len(c.NamePath) == 0 {
+		c.NamePath = defaults.NamePath
+	}
+	if len(c.VersionPath) == 0 {
+		c.VersionPath = defaults.VersionPath
+	}
+	if c.Format == nil {
+		c.Format = defaults.Format
+	}
+	if _, err := regexp.Compile(c.Format.Match); err != nil {
+		return fmt.Errorf("invalid supplied regexp '%s' : %s", c.Format.Match, err)
+	}
+	for _, p := range []string{c.NamePath, c.VersionPath} {
+		if _, err := manifestPointer(p); err != nil {
+			return fmt.Errorf("invalid path '%s': %s", p, err)
+		}
+	}
+	return nil
+}
+
+// Match -
+func (c *VersionExtractorConfig) Match(name string) bool {
+	for _, m := range c.Matchers {
+		if regexp.MustCompile(m).MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Name - Extracts manifest name from given deployment manifest, empty when not found
+func (c *VersionExtractorConfig) Name(manifest interface{}) string {
+	return findString(manifest, c.NamePath)
+}
+
+// Version - Extracts formatted manifest version from given deployment manifest, empty when not found
+func (c *VersionExtractorConfig) Version(manifest interface{}) string {
+	value := findString(manifest, c.VersionPath)
+	if value == "" {
+		return ""
+	}
+	return c.Format.Format(value)
+}
+
+// manifestPointer - Creates go-patch pointer from either a go-patch path or a dotted yaml path
+func manifestPointer(path string) (patch.Pointer, error) {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + strings.ReplaceAll(path, ".", "/")
+	}
+	return patch.NewPointerFromString(path)
+}
+
+// findString - Gives string or integer value at given path, empty when not found
+//
+// Floats are rejected, formatting them loses their original text, ie: 1.10 gives 1.1.
+// Manifests should be decoded with decodeRaw which keeps the text of all scalars.
+func findString(manifest interface{}, path string) string {
+	pointer, err := manifestPointer(path)
+	if err != nil {
+		return ""
+	}
+	value, err := patch.FindOp{Path: pointer}.Apply(manifest)
+	if err != nil || value == nil {
+		return ""
+	}
+	switch value.(type) {
+	case string, int, int64, uint64:
+		return fmt.Sprintf("%v", value)
+	case float64:
+		log.Errorf("ignoring float value '%v' at path '%s', value must be quoted", value, path)
+	}
+	return ""
+}
+
+// rawNode - Decodes any yaml value, scalars being kept as their original text
+type rawNode struct {
+	value interface{}
+}
+
+// UnmarshalYAML - Implements yaml.Unmarshaler
+//
+// Null values decode to nil maps and slices without error, they are then tried as scalars.
+func (n *rawNode) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var m map[interface{}]*rawNode
+	if err := unmarshal(&m); err == nil && m != nil {
+		res := map[interface{}]interface{}{}
+		for k, v := range m {
+			res[k] = v.get()
+		}
+		n.value = res
+		return nil
+	}
+	var l []*rawNode
+	if err := unmarshal(&l); err == nil && l != nil {
+		res := []interface{}{}
+		for _, v := range l {
+			res = append(res, v.get())
+		}
+		n.value = res
+		return nil
+	}
+	var s *string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	if s != nil {
+		n.value = *s
+	}
+	return nil
+}
+
+func (n *rawNode) get() interface{} {
+	if n == nil {
+		return nil
+	}
+	return n.value
+}
+
+// decodeRaw - Decodes yaml document, scalars being kept as their original text
+func decodeRaw(content []byte) (interface{}, error) {
+	var res rawNode
+	if err := yaml.Unmarshal(content, &res); err != nil {
+		return nil, err
+	}
+	return res.value, nil
+}
+
+// LabelsConfig - Deployment information exposed as extra metric labels
+type LabelsConfig struct {
+	Tags       []string          `yaml:"tags"`
+	Teams      bool              `yaml:"teams"`
+	Properties map[string]string `yaml:"properties"`
+}
+
+var (
+	labelNameRegexp = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$")
+	labelSanitizer  = regexp.MustCompile("[^a-zA-Z0-9_]")
+	reservedLabels  = map[string]bool{
+		"environment": true, "deployment": true, "name": true, "current": true, "latest": true,
+		"detection": true, "confidence": true, "teams": true, "manifest_name": true,
+		"manifest_current": true, "manifest_latest": true, "boshrelease_name": true,
+		"boshrelease_current": true, "boshrelease_latest": true,
+	}
+)
+
+// validate - Checks that label names are valid, not reserved and unique once tags are
+// sanitized, metrics registration would panic otherwise
+func (c *LabelsConfig) validate() error {
+	for _, t := range c.Tags {
+		if len(t) == 0 {
+			return fmt.Errorf("invalid empty tag name")
+		}
+	}
+	for name, path := range c.Properties {
+		if !labelNameRegexp.MatchString(name) || strings.HasPrefix(name, "tag_") {
+			return fmt.Errorf("invalid label name '%s'", name)
+		}
+		if reservedLabels[name] {
+			return fmt.Errorf("reserved label name '%s'", name)
+		}
+		if _, err := manifestPointer(path); err != nil {
+			return fmt.Errorf("invalid path '%s': %s", path, err)
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, name := range c.Names() {
+		if seen[name] {
+			return fmt.Errorf("duplicated label name '%s'", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// Names - Gives extra label names, values given by Values follow the same order
+//
+// Tags are prefixed by 'tag_' and sanitized to form valid label names.
+func (c *LabelsConfig) Names() []string {
+	res := []string{}
+	for _, t := range c.Tags {
+		res = append(res, "tag_"+labelSanitizer.ReplaceAllString(t, "_"))
+	}
+	if c.Teams {
+		res = append(res, "teams")
+	}
+	res = append(res, c.propertyNames()...)
+	return res
+}
+
+// Values - Gives extra label values of given deployment
+func (c *LabelsConfig) Values(deployment BoshDeploymentData) []string {
+	res := []string{}
+	for _, t := range c.Tags {
+		res = append(res, deployment.Tags[t])
+	}
+	if c.Teams {
+		res = append(res, strings.Join(deployment.Teams, ","))
+	}
+	for _, name := range c.propertyNames() {
+		res = append(res, deployment.Properties[name])
+	}
+	return res
+}
+
+func (c *LabelsConfig) propertyNames() []string {
+	res := []string{}
+	for name := range c.Properties {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
 // BoshConfig -
 type BoshConfig struct {
-	URL          string   `yaml:"url"`
-	LogLevel     string   `yaml:"log_level"`
-	CaCert       string   `yaml:"ca_cert"`
-	Username     string   `yaml:"username"`
-	Password     string   `yaml:"password"`
-	ClientID     string   `yaml:"client_id"`
-	ClientSecret string   `yaml:"client_secret"`
-	Excludes     []string `yaml:"excludes"`
-	Proxy        string   `yaml:"proxy"`
-}
-
-func (c *BoshConfig) validate() error {
+	URL          string                   `yaml:"url"`
+	LogLevel     string                   `yaml:"log_level"`
+	CaCert       string                   `yaml:"ca_cert"`
+	Username     string                   `yaml:"username"`
+	Password     string                   `yaml:"password"`
+	ClientID     string                   `yaml:"client_id"`
+	ClientSecret string                   `yaml:"client_secret"`
+	Excludes     []string                 `yaml:"excludes"`
+	Proxy        string                   `yaml:"proxy"`
+	Jumpbox      *JumpboxConfig           `yaml:"jumpbox"`
+	Extractors   []VersionExtractorConfig `yaml:"extractors"`
+	Labels       LabelsConfig             `yaml:"labels"`
+	Source       string                   `yaml:"director_source"`
+	Deep         bool                     `yaml:"deep"`
+	Timeout      string                   `yaml:"timeout"`
+	MaxBackoff   string                   `yaml:"max_backoff"`
+}
+
+// validate - Checks configuration and reads credential files, unless offline
+func (c *BoshConfig) validate(offline bool) error {
 	if len(c.URL) == 0 {
 		c.URL = os.Getenv("BOSH_ENVIRONMENT")
 		if len(c.URL) == 0 {
@@ -39,7 +298,7 @@ func (c *BoshConfig) validate() error {
 	}
 	if len(c.CaCert) == 0 {
 		c.CaCert = os.Getenv("BOSH_CA_CERT")
-	} else {
+	} else if !offline {
 		val, err := os.ReadFile(c.CaCert)
 		if err != nil {
 			return fmt.Errorf("unable to read file at path %s", c.CaCert)
@@ -47,18 +306,79 @@ func (c *BoshConfig) validate() error {
 		c.CaCert = string(val)
 	}
 
-	if len(c.Proxy) == 0 {
+	if len(c.Proxy) == 0 && c.Jumpbox == nil {
 		c.Proxy = os.Getenv("BOSH_ALL_PROXY")
 	}
+	if len(c.Proxy) != 0 {
+		if c.Jumpbox != nil {
+			return fmt.Errorf("proxy and jumpbox are mutually exclusive")
+		}
+		proxyURL, jumpbox, err := parseProxyURL(c.Proxy)
+		if err != nil {
+			return err
+		}
+		c.Proxy, c.Jumpbox = proxyURL, jumpbox
+	}
+	if c.Jumpbox != nil {
+		if err := c.Jumpbox.validate(offline); err != nil {
+			return fmt.Errorf("invalid jumpbox, %s", err)
+		}
+	}
+
+	if len(c.Timeout) == 0 {
+		c.Timeout = "1m"
+	}
+	if _, err := time.ParseDuration(c.Timeout); err != nil {
+		return fmt.Errorf("invalid duration format for timeout")
+	}
+	if len(c.MaxBackoff) == 0 {
+		c.MaxBackoff = "5m"
+	}
+	if _, err := time.ParseDuration(c.MaxBackoff); err != nil {
+		return fmt.Errorf("invalid duration format for max_backoff")
+	}
 
 	for _, f := range c.Excludes {
 		if _, err := regexp.Compile(f); err != nil {
 			return fmt.Errorf("invalid exclude filter regexp '%s'", f)
 		}
 	}
+
+	for idx := range c.Extractors {
+		if err := c.Extractors[idx].validate(); err != nil {
+			return fmt.Errorf("invalid extractor, %s", err)
+		}
+	}
+
+	if err := c.Labels.validate(); err != nil {
+		return fmt.Errorf("invalid labels, %s", err)
+	}
 	return nil
 }
 
+// Extractor - Gives first version extractor matching given deployment name, defaults
+// to manifest_name and manifest_version keys
+func (c *BoshConfig) Extractor(name string) VersionExtractorConfig {
+	for _, e := range c.Extractors {
+		if e.Match(name) {
+			return e
+		}
+	}
+	return NewVersionExtractorConfig()
+}
+
+// RequestTimeout - Gives maximum duration of a director request
+func (c *BoshConfig) RequestTimeout() time.Duration {
+	val, _ := time.ParseDuration(c.Timeout)
+	return val
+}
+
+// RetryMaxBackoff - Gives maximum delay between two director connection attempts
+func (c *BoshConfig) RetryMaxBackoff() time.Duration {
+	val, _ := time.ParseDuration(c.MaxBackoff)
+	return val
+}
+
 // IsExcluded - Tells if name is matching one of configured exclude filters
 func (c *BoshConfig) IsExcluded(name string) bool {
 	for _, f := range c.Excludes {
@@ -77,26 +397,24 @@ func buildLogger(config BoshConfig) (logger.Logger, error) {
 	return logger.NewLogger(level), nil
 }
 
-func buildUAA(url string, config BoshConfig, logger logger.Logger) (uaa.UAA, error) {
+func buildUAA(url string, config BoshConfig, dialer httpclient.DialContextFunc, logger logger.Logger) (uaa.Client, error) {
 	uaaConfig, err := uaa.NewConfigFromURL(url)
 	if err != nil {
-		return nil, err
+		return uaa.Client{}, err
 	}
 	uaaConfig.CACert = config.CaCert
 	uaaConfig.Client = config.ClientID
 	uaaConfig.ClientSecret = config.ClientSecret
-	uaaFactory := uaa.NewFactory(logger)
-	return uaaFactory.New(uaaConfig)
+	return newUAAClient(uaaConfig, dialer, logger)
 }
 
-func getDirectorInfo(config BoshConfig, logger logger.Logger) (*director.Info, error) {
+func getDirectorInfo(config BoshConfig, dialer httpclient.DialContextFunc, logger logger.Logger) (*director.Info, error) {
 	directorConfig, err := director.NewConfigFromURL(config.URL)
 	if err != nil {
 		return nil, err
 	}
 	directorConfig.CACert = config.CaCert
-	factory := director.NewFactory(logger)
-	anonymousDirector, err := factory.New(directorConfig, nil, nil)
+	anonymousDirector, err := newDirectorClient(directorConfig, dialer, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -104,21 +422,23 @@ func getDirectorInfo(config BoshConfig, logger logger.Logger) (*director.Info, e
 	return &info, err
 }
 
-// NewDirector -
-func NewDirector(config BoshConfig) (director.Director, error) {
-	if config.Proxy != "" {
-		err := os.Setenv("BOSH_ALL_PROXY", config.Proxy)
-		if err != nil {
-			return nil, fmt.Errorf("failed to set BOSH_ALL_PROXY: %s", err)
-		}
+// NewDirector - Connects to director and its UAA with configured proxy or jumpbox
+//
+// Connections are closed after configured timeout. Dialer is dedicated to config,
+// neither process environment nor bosh-utils defaults are modified.
+func NewDirector(config BoshConfig) (*DirectorClient, error) {
+	log, err := buildLogger(config)
+	if err != nil {
+		return nil, err
 	}
 
-	log, err := buildLogger(config)
+	dialer, err := newProxyDialer(config)
 	if err != nil {
 		return nil, err
 	}
+	dialer = withDeadline(dialer, config.RequestTimeout())
 
-	infos, err := getDirectorInfo(config, log)
+	infos, err := getDirectorInfo(config, dialer, log)
 	if err != nil {
 		return nil, err
 	}
@@ -137,13 +457,12 @@ func NewDirector(config BoshConfig) (director.Director, error) {
 		if !ok {
 			return nil, fmt.Errorf("expected UAA URL '%s' to be a string", uaaURL)
 		}
-		uaaCli, err := buildUAA(uaaURLStr, config, log)
+		uaaCli, err := buildUAA(uaaURLStr, config, dialer, log)
 		if err != nil {
 			return nil, err
 		}
-		directorConfig.TokenFunc = uaa.NewClientTokenSession(uaaCli).TokenFunc
+		directorConfig.TokenFunc = (&tokenSession{uaa: uaaCli}).TokenFunc
 	}
 
-	factory := director.NewFactory(log)
-	return factory.New(directorConfig, director.NewNoopTaskReporter(), director.NewNoopFileReporter())
+	return newDirectorClient(directorConfig, dialer, log)
 }
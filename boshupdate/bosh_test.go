@@ -0,0 +1,95 @@
+package boshupdate
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestFindString(t *testing.T) {
+	manifest := []byte(`
+name: cf
+manifest_version: 1.10
+count: 3
+quoted: "1.10"
+enabled: true
+empty:
+tags:
+  version: v2.1.0
+instance_groups:
+- name: api
+  properties:
+    owner: team-a
+`)
+	raw, err := decodeRaw(manifest)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var plain interface{}
+	if err = yaml.Unmarshal(manifest, &plain); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		name     string
+		manifest interface{}
+		path     string
+		expected string
+	}{
+		{"raw string", raw, "/name", "cf"},
+		{"raw unquoted float keeps text", raw, "/manifest_version", "1.10"},
+		{"raw integer", raw, "/count", "3"},
+		{"raw quoted", raw, "/quoted", "1.10"},
+		{"raw null", raw, "/empty", ""},
+		{"raw dotted path", raw, "tags.version", "v2.1.0"},
+		{"raw go-patch path", raw, "/instance_groups/name=api/properties/owner", "team-a"},
+		{"raw map", raw, "/tags", ""},
+		{"raw missing", raw, "/missing", ""},
+		{"plain float rejected", plain, "/manifest_version", ""},
+		{"plain integer", plain, "/count", "3"},
+		{"plain bool rejected", plain, "/enabled", ""},
+		{"plain string", plain, "/quoted", "1.10"},
+	}
+	for _, tt := range tests {
+		if res := findString(tt.manifest, tt.path); res != tt.expected {
+			t.Errorf("%s: expected '%s', got '%s'", tt.name, tt.expected, res)
+		}
+	}
+}
+
+func TestLabelsConfigNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   LabelsConfig
+		expected []string
+		valid    bool
+	}{
+		{"empty", LabelsConfig{}, []string{}, true},
+		{
+			"ordered tags, teams and sorted properties",
+			LabelsConfig{
+				Tags:       []string{"squad", "cost-center"},
+				Teams:      true,
+				Properties: map[string]string{"owner": "/owner", "az": "/az"},
+			},
+			[]string{"tag_squad", "tag_cost_center", "teams", "az", "owner"},
+			true,
+		},
+		{"sanitized tag duplicates", LabelsConfig{Tags: []string{"a-b", "a_b"}}, []string{"tag_a_b", "tag_a_b"}, false},
+		{"same tag twice", LabelsConfig{Tags: []string{"squad", "squad"}}, []string{"tag_squad", "tag_squad"}, false},
+		{"reserved property", LabelsConfig{Properties: map[string]string{"deployment": "/d"}}, []string{"deployment"}, false},
+		{"teams property", LabelsConfig{Properties: map[string]string{"teams": "/t"}}, []string{"teams"}, false},
+		{"tag prefixed property", LabelsConfig{Properties: map[string]string{"tag_x": "/x"}}, []string{"tag_x"}, false},
+		{"invalid property name", LabelsConfig{Properties: map[string]string{"a-b": "/x"}}, []string{"a-b"}, false},
+		{"empty tag", LabelsConfig{Tags: []string{""}}, []string{"tag_"}, false},
+	}
+	for _, tt := range tests {
+		if res := tt.config.Names(); !reflect.DeepEqual(res, tt.expected) {
+			t.Errorf("%s: expected names %v, got %v", tt.name, tt.expected, res)
+		}
+		if err := tt.config.validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid %v, got error %v", tt.name, tt.valid, err)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package boshupdate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudfoundry/bosh-cli/director"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// number of recent tasks searched for the latest deploy of a deployment
+	manifestTaskLimit = 30
+	// description of tasks updating deployment manifests
+	manifestTaskDescription = "create deployment"
+)
+
+// manifestEntry - Downloaded and parsed manifest of a deployment
+type manifestEntry struct {
+	key      string
+	manifest string
+	doc      interface{}
+	releases []BoshRelease
+	tags     map[string]string
+}
+
+// CacheStats - Cumulative hits and misses of deployment manifest cache
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// ManifestCacheStats - Gives cumulative hits and misses of deployment manifest cache
+func (a *Manager) ManifestCacheStats() CacheStats {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.manifestStats
+}
+
+// manifestKey - Computes a cheap signature of deployment state
+//
+// Deployments list already gives releases and stemcells, latest deploy task id
+// costs one small request and catches changes of properties or ops-files.
+func (a *Manager) manifestKey(deployment *boshDeployment) (string, error) {
+	parts := []string{}
+
+	for _, r := range deployment.Releases {
+		parts = append(parts, fmt.Sprintf("r:%s/%s", r.Name, r.Version))
+	}
+	for _, s := range deployment.Stemcells {
+		parts = append(parts, fmt.Sprintf("s:%s/%s", s.Name, s.Version))
+	}
+	sort.Strings(parts)
+
+	task, err := a.lastDeployTask(deployment.Name)
+	if err != nil {
+		return "", err
+	}
+	taskID := 0
+	if task != nil {
+		taskID = task.ID
+	}
+
+	return fmt.Sprintf("%d|%s", taskID, strings.Join(parts, ",")), nil
+}
+
+// lastDeployTask - Gives most recent task updating manifest of given deployment, nil when
+// none is found in recent tasks
+func (a *Manager) lastDeployTask(name string) (*director.TaskResp, error) {
+	tasks, err := a.director.RecentTasks(manifestTaskLimit, director.TasksFilter{Deployment: name})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to fetch deployment tasks")
+	}
+	var res *director.TaskResp
+	for idx, t := range tasks {
+		if strings.HasPrefix(t.Description, manifestTaskDescription) && (res == nil || t.ID > res.ID) {
+			res = &tasks[idx]
+		}
+	}
+	return res, nil
+}
+
+// getManifest - Gives parsed manifest of deployment, downloaded only when deployment state changed
+//
+// Failing to compute the state signature is not fatal, manifest is then downloaded
+// and not cached.
+func (a *Manager) getManifest(deployment *boshDeployment) (*manifestEntry, error) {
+	entry := log.WithField("deployment", deployment.Name)
+
+	key, err := a.manifestKey(deployment)
+	if err != nil {
+		entry.Warnf("unable to compute manifest cache key: %s", err)
+	}
+
+	if key != "" {
+		a.mutex.Lock()
+		cached, found := a.manifestCache[deployment.Name]
+		if found && cached.key == key {
+			a.manifestStats.Hits++
+			a.mutex.Unlock()
+			entry.Debugf("using cached manifest")
+			return cached, nil
+		}
+		a.manifestStats.Misses++
+		a.mutex.Unlock()
+	}
+
+	manifest, err := deployment.Manifest()
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to fetch manifest")
+	}
+
+	data := struct {
+		Releases []BoshRelease     `yaml:"releases"`
+		Tags     map[string]string `yaml:"tags"`
+	}{}
+	var doc interface{}
+	if err = yaml.Unmarshal([]byte(manifest), &data); err == nil {
+		doc, err = decodeRaw([]byte(manifest))
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse manifest")
+	}
+
+	res := &manifestEntry{
+		key:      key,
+		manifest: manifest,
+		doc:      doc,
+		releases: data.Releases,
+		tags:     data.Tags,
+	}
+	if key != "" {
+		a.mutex.Lock()
+		a.manifestCache[deployment.Name] = res
+		a.mutex.Unlock()
+	}
+	return res, nil
+}
+
+// pruneManifests - Forgets cached manifests of deployments that no longer exist
+func (a *Manager) pruneManifests(deployments []*boshDeployment) {
+	names := map[string]bool{}
+	for _, d := range deployments {
+		names[d.Name] = true
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for name := range a.manifestCache {
+		if !names[name] {
+			delete(a.manifestCache, name)
+		}
+	}
+}
+
+// repoRefKey - Gives cache key prefix of given repository and ref
+func repoRefKey(item GenericReleaseConfig, ref string) string {
+	return fmt.Sprintf("%s/%s@%s", item.Owner, item.Repo, ref)
+}
+
+// pruneRenders - Forgets rendered releases, file contents and listings of refs no longer in use
+//
+//  1. Refs in use are latest versions, and versions considered by version detection.
+//  2. Repositories of releases not processed, ie: filtered out, are kept untouched.
+func (a *Manager) pruneRenders(items []ManifestReleaseData) {
+	used := map[string]bool{}
+	repos := map[string]bool{}
+	for _, item := range items {
+		if item.HasError {
+			continue
+		}
+		repos[repoRefKey(item.GenericReleaseConfig, "")] = true
+		// 1.
+		used[repoRefKey(item.GenericReleaseConfig, item.LatestVersion.GitRef)] = true
+		for idx := 0; idx < item.DetectVersions && idx < len(item.Versions); idx++ {
+			used[repoRefKey(item.GenericReleaseConfig, item.Versions[idx].GitRef)] = true
+		}
+	}
+
+	isStale := func(key string) bool {
+		prefix, _, _ := strings.Cut(key, "|")
+		repo, _, _ := strings.Cut(prefix, "@")
+		// 2.
+		return repos[repo+"@"] && !used[prefix]
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for key := range a.renderCache {
+		if isStale(key) {
+			delete(a.renderCache, key)
+		}
+	}
+	for key := range a.contentCache {
+		if isStale(key) {
+			delete(a.contentCache, key)
+		}
+	}
+	for key := range a.listCache {
+		if isStale(key) {
+			delete(a.listCache, key)
+		}
+	}
+}
+
+// Local Variables:
+// ispell-local-dictionary: "american"
+// End:
@@ -0,0 +1,54 @@
+package boshupdate
+
+import (
+	"testing"
+)
+
+func TestPruneRenders(t *testing.T) {
+	cf := ManifestReleaseData{
+		ManifestReleaseConfig: ManifestReleaseConfig{
+			GenericReleaseConfig: GenericReleaseConfig{Owner: "cloudfoundry", Repo: "cf-deployment"},
+			DetectVersions:       2,
+		},
+		Versions: []Version{
+			{GitRef: "v3"}, {GitRef: "v2"}, {GitRef: "v1"},
+		},
+		LatestVersion: Version{GitRef: "v3"},
+	}
+	m := &Manager{
+		renderCache: map[string][]BoshRelease{
+			"cloudfoundry/cf-deployment@v3|cf.yml||":    nil,
+			"cloudfoundry/cf-deployment@v3|other.yml||": nil,
+			"cloudfoundry/cf-deployment@v2|cf.yml||":    nil,
+			"cloudfoundry/cf-deployment@v1|cf.yml||":    nil,
+			"other/repo@v1|m.yml||":                     nil,
+		},
+		contentCache: map[string][]byte{
+			"cloudfoundry/cf-deployment@v3|cf.yml": nil,
+			"cloudfoundry/cf-deployment@v0|cf.yml": nil,
+		},
+	}
+	m.pruneRenders([]ManifestReleaseData{cf})
+
+	tests := []struct {
+		key  string
+		kept bool
+	}{
+		{"cloudfoundry/cf-deployment@v3|cf.yml||", true},
+		{"cloudfoundry/cf-deployment@v3|other.yml||", true},
+		{"cloudfoundry/cf-deployment@v2|cf.yml||", true},
+		{"cloudfoundry/cf-deployment@v1|cf.yml||", false},
+		{"other/repo@v1|m.yml||", true},
+	}
+	for _, tt := range tests {
+		if _, found := m.renderCache[tt.key]; found != tt.kept {
+			t.Errorf("render cache entry '%s': expected kept %v", tt.key, tt.kept)
+		}
+	}
+	if _, found := m.contentCache["cloudfoundry/cf-deployment@v3|cf.yml"]; !found {
+		t.Errorf("content of latest version must be kept")
+	}
+	if _, found := m.contentCache["cloudfoundry/cf-deployment@v0|cf.yml"]; found {
+		t.Errorf("content of stale version must be pruned")
+	}
+}
@@ -0,0 +1,198 @@
+package boshupdate
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/cloudfoundry/bosh-cli/director"
+	"github.com/cloudfoundry/bosh-cli/uaa"
+	"github.com/cloudfoundry/bosh-utils/httpclient"
+	"github.com/cloudfoundry/bosh-utils/logger"
+	"github.com/pkg/errors"
+)
+
+const (
+	// attempts of requests failing on network errors, as bosh-cli
+	requestAttempts = 5
+	// delay between two attempts, as bosh-cli
+	requestRetryDelay = 500 * time.Millisecond
+)
+
+// newHTTPClient - Creates bosh-utils default http client with a transport of its own
+//
+// Transport dials through given dialer instead of the process wide one bosh-utils
+// builds from BOSH_ALL_PROXY, each director keeps its own proxy.
+func newHTTPClient(certPool *x509.CertPool, dialer httpclient.DialContextFunc) *http.Client {
+	client := httpclient.CreateDefaultClient(certPool)
+	client.Transport.(*http.Transport).DialContext = dialer
+	return client
+}
+
+// DirectorClient - Director API requests used by the manager
+//
+// Vendored bosh-cli factories only build clients dialing through the process wide
+// proxy, client is assembled from bosh-cli building blocks instead, see NewDirector.
+type DirectorClient struct {
+	client  director.Client
+	request director.ClientRequest
+}
+
+// newDirectorClient - Creates director client dialing through given dialer, authenticated
+// according to given configuration
+func newDirectorClient(config director.FactoryConfig, dialer httpclient.DialContextFunc, logger logger.Logger) (*DirectorClient, error) {
+	if err := config.Validate(); err != nil {
+		return nil, errors.Wrapf(err, "invalid director connection configuration")
+	}
+	certPool, err := config.CACertPool()
+	if err != nil {
+		return nil, err
+	}
+
+	rawClient := newHTTPClient(certPool, dialer)
+	adjustment := director.NewAuthRequestAdjustment(config.TokenFunc, config.Client, config.ClientSecret)
+	rawClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) > 10 {
+			return fmt.Errorf("too many redirects")
+		}
+		// redirected requests are not retried, token must be valid
+		return adjustment.Adjust(req, true)
+	}
+	retryClient := httpclient.NewNetworkSafeRetryClient(rawClient, requestAttempts, requestRetryDelay, logger)
+	httpClient := httpclient.NewHTTPClientOpts(
+		director.NewAdjustableClient(retryClient, adjustment),
+		logger,
+		httpclient.Opts{NoRedactUrlQuery: true},
+	)
+
+	endpoint := url.URL{
+		Scheme: "https",
+		Host:   net.JoinHostPort(config.Host, strconv.Itoa(config.Port)),
+	}
+	fileReporter := director.NewNoopFileReporter()
+	return &DirectorClient{
+		client:  director.NewClient(endpoint.String(), httpClient, director.NewNoopTaskReporter(), fileReporter, logger),
+		request: director.NewClientRequest(endpoint.String(), httpClient, fileReporter, logger),
+	}, nil
+}
+
+// newUAAClient - Creates UAA client dialing through given dialer
+func newUAAClient(config uaa.Config, dialer httpclient.DialContextFunc, logger logger.Logger) (uaa.Client, error) {
+	if err := config.Validate(); err != nil {
+		return uaa.Client{}, errors.Wrapf(err, "invalid UAA connection configuration")
+	}
+	certPool, err := config.CACertPool()
+	if err != nil {
+		return uaa.Client{}, err
+	}
+
+	retryClient := httpclient.NewNetworkSafeRetryClient(newHTTPClient(certPool, dialer), requestAttempts, requestRetryDelay, logger)
+	endpoint := url.URL{
+		Scheme: "https",
+		Host:   net.JoinHostPort(config.Host, strconv.Itoa(config.Port)),
+		Path:   config.Path,
+	}
+	return uaa.NewClient(endpoint.String(), config.Client, config.ClientSecret, httpclient.NewHTTPClient(retryClient, logger), logger), nil
+}
+
+// Info -
+func (c *DirectorClient) Info() (director.Info, error) {
+	r, err := c.client.Info()
+	if err != nil {
+		return director.Info{}, err
+	}
+	res := director.Info{
+		Name:    r.Name,
+		UUID:    r.UUID,
+		Version: r.Version,
+		User:    r.User,
+		Auth: director.UserAuthentication{
+			Type:    r.Auth.Type,
+			Options: r.Auth.Options,
+		},
+		Features:        map[string]bool{},
+		CPI:             r.CPI,
+		StemcellOS:      r.StemcellOS,
+		StemcellVersion: r.StemcellVersion,
+	}
+	for k, feature := range r.Features {
+		res.Features[k] = feature.Status
+	}
+	return res, nil
+}
+
+// Deployments - Lists deployments with their releases, stemcells and teams
+func (c *DirectorClient) Deployments() ([]director.DeploymentResp, error) {
+	return c.client.Deployments()
+}
+
+// ListDeployments - Lists deployments, without their configs
+func (c *DirectorClient) ListDeployments() ([]director.DeploymentResp, error) {
+	return c.client.DeploymentsWithoutConfigs()
+}
+
+// Manifest - Gives manifest of given deployment
+func (c *DirectorClient) Manifest(deployment string) (string, error) {
+	resp, err := c.client.Deployment(deployment)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to fetch manifest")
+	}
+	return resp.Manifest, nil
+}
+
+// InstanceInfos - Gives instances of given deployment
+func (c *DirectorClient) InstanceInfos(deployment string) ([]director.VMInfo, error) {
+	return c.client.DeploymentInstanceInfos(deployment)
+}
+
+// Variables - Gives config server variables of given deployment
+func (c *DirectorClient) Variables(deployment string) ([]director.VariableResult, error) {
+	res := []director.VariableResult{}
+	path := (&url.URL{Path: fmt.Sprintf("/deployments/%s/variables", deployment)}).RequestURI()
+	if err := c.request.Get(path, &res); err != nil {
+		return nil, errors.Wrapf(err, "unable to fetch variables of deployment '%s'", deployment)
+	}
+	return res, nil
+}
+
+// Releases - Lists uploaded releases with their versions
+func (c *DirectorClient) Releases() ([]director.ReleaseSeriesResp, error) {
+	return c.client.ReleaseSeries()
+}
+
+// Stemcells - Lists uploaded stemcells
+func (c *DirectorClient) Stemcells() ([]director.StemcellResp, error) {
+	return c.client.Stemcells()
+}
+
+// ListConfigs - Lists configs matching filter, only latest ones when limit is 1
+func (c *DirectorClient) ListConfigs(limit int, filter director.ConfigsFilter) ([]director.Config, error) {
+	query := url.Values{}
+	if filter.Type != "" {
+		query.Add("type", filter.Type)
+	}
+	if filter.Name != "" {
+		query.Add("name", filter.Name)
+	}
+	query.Add("limit", strconv.Itoa(limit))
+	query.Add("latest", strconv.FormatBool(limit == 1))
+
+	res := []director.Config{}
+	if err := c.request.Get("/configs?"+query.Encode(), &res); err != nil {
+		return nil, errors.Wrapf(err, "unable to list configs")
+	}
+	return res, nil
+}
+
+// RecentTasks - Lists most recent tasks matching filter
+func (c *DirectorClient) RecentTasks(limit int, filter director.TasksFilter) ([]director.TaskResp, error) {
+	return c.client.RecentTasks(limit, filter)
+}
+
+// Local Variables:
+// ispell-local-dictionary: "american"
+// End:
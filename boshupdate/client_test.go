@@ -0,0 +1,82 @@
+package boshupdate
+
+import (
+	"context"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cloudfoundry/bosh-cli/director"
+	"github.com/cloudfoundry/bosh-utils/logger"
+)
+
+func TestDirectorClient(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, _, _ := r.BasicAuth(); user != "admin" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/info":
+			w.Write([]byte(`{"name":"test","version":"280.0.0 (00000000)","user_authentication":{"type":"basic"}}`)) //nolint:errcheck
+		case "/deployments":
+			w.Write([]byte(`[{"name":"cf","releases":[{"name":"capi","version":"1.0"}],"teams":["team"]}]`)) //nolint:errcheck
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("BOSH_ALL_PROXY", "socks5://127.0.0.1:1")
+	environ := os.Environ()
+
+	var dials atomic.Int32
+	direct := &net.Dialer{}
+	dialer := func(ctx context.Context, network, address string) (net.Conn, error) {
+		dials.Add(1)
+		return direct.DialContext(ctx, network, address)
+	}
+
+	config, err := director.NewConfigFromURL(server.URL)
+	if err != nil {
+		t.Fatalf("unable to parse director url: %s", err)
+	}
+	config.CACert = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	config.Client = "admin"
+	config.ClientSecret = "secret"
+
+	client, err := newDirectorClient(config, dialer, logger.NewLogger(logger.LevelNone))
+	if err != nil {
+		t.Fatalf("unable to create director client: %s", err)
+	}
+	info, err := client.Info()
+	if err != nil {
+		t.Fatalf("unable to fetch director info: %s", err)
+	}
+	if info.Name != "test" || info.Auth.Type != "basic" {
+		t.Errorf("unexpected director info %+v", info)
+	}
+	deployments, err := client.Deployments()
+	if err != nil {
+		t.Fatalf("unable to fetch deployments: %s", err)
+	}
+	if len(deployments) != 1 || deployments[0].Name != "cf" || deployments[0].Releases[0].Version != "1.0" {
+		t.Errorf("unexpected deployments %+v", deployments)
+	}
+	if _, err := client.Manifest("unknown"); err == nil || isUnavailable(err) {
+		t.Errorf("expected client error fetching manifest of unknown deployment, got %v", err)
+	}
+
+	if dials.Load() != 3 {
+		t.Errorf("expected 3 connections through director dialer, got %d", dials.Load())
+	}
+	if !reflect.DeepEqual(os.Environ(), environ) {
+		t.Errorf("expected environment to be left untouched")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -15,10 +16,11 @@ import (
 
 // GenericReleaseConfig -
 type GenericReleaseConfig struct {
-	Owner  string     `yaml:"owner"`
-	Repo   string     `yaml:"repo"`
-	Types  []string   `yaml:"types"`
-	Format *Formatter `yaml:"format"`
+	Owner       string     `yaml:"owner"`
+	Repo        string     `yaml:"repo"`
+	Types       []string   `yaml:"types"`
+	Format      *Formatter `yaml:"format"`
+	BoshRelease string     `yaml:"bosh_release"`
 }
 
 func (c *GenericReleaseConfig) validate(name string) error {
@@ -69,13 +71,83 @@ func (c *GenericReleaseConfig) HasType(name string) bool {
 	return false
 }
 
+// ManifestOverrideConfig -
+type ManifestOverrideConfig struct {
+	Matchers []string `yaml:"matchers"`
+	Ops      []string `yaml:"ops"`
+	Vars     []string `yaml:"vars"`
+}
+
+// Match -
+func (c *ManifestOverrideConfig) Match(name string) bool {
+	for _, m := range c.Matchers {
+		re := regexp.MustCompile(m)
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *ManifestOverrideConfig) validate() error {
+	if len(c.Matchers) == 0 {
+		return fmt.Errorf("missing mandatory override matchers")
+	}
+	for _, m := range c.Matchers {
+		if _, err := regexp.Compile(m); err != nil {
+			return fmt.Errorf("invalid override match regexp '%s'", m)
+		}
+	}
+	return nil
+}
+
 // ManifestReleaseConfig -
 type ManifestReleaseConfig struct {
 	GenericReleaseConfig `yaml:",inline"`
-	Manifest             string   `yaml:"manifest"`
-	Ops                  []string `yaml:"ops"`
-	Vars                 []string `yaml:"vars"`
-	Matchers             []string `yaml:"matchers"`
+	Manifest             string                   `yaml:"manifest"`
+	Ops                  []string                 `yaml:"ops"`
+	Vars                 []string                 `yaml:"vars"`
+	Matchers             []string                 `yaml:"matchers"`
+	DeploymentVars       bool                     `yaml:"deployment_vars"`
+	Overrides            []ManifestOverrideConfig `yaml:"overrides"`
+	OpsDir               string                   `yaml:"ops_dir"`
+	InferOps             bool                     `yaml:"infer_ops"`
+	DetectVersions       int                      `yaml:"detect_versions"`
+	RequiredStops        []string                 `yaml:"required_stops"`
+	BreakingPatterns     []string                 `yaml:"breaking_patterns"`
+	// compiled breaking patterns, set by validate
+	breakingRegexps []*regexp.Regexp
+}
+
+// HasOverride - Tells if one of the overrides matches given deployment
+func (c *ManifestReleaseConfig) HasOverride(deployment string) bool {
+	for _, o := range c.Overrides {
+		if o.Match(deployment) {
+			return true
+		}
+	}
+	return false
+}
+
+// OpsFor - Gives ops-files and vars-files to apply when rendering manifest for given deployment
+//
+// First matching override wins, ops and vars given by an override replace
+// global ones only when defined.
+func (c *ManifestReleaseConfig) OpsFor(deployment string) ([]string, []string) {
+	for _, o := range c.Overrides {
+		if !o.Match(deployment) {
+			continue
+		}
+		ops, vars := c.Ops, c.Vars
+		if o.Ops != nil {
+			ops = o.Ops
+		}
+		if o.Vars != nil {
+			vars = o.Vars
+		}
+		return ops, vars
+	}
+	return c.Ops, c.Vars
 }
 
 func (c *ManifestReleaseConfig) Match(name string) bool {
@@ -100,40 +172,122 @@ func (c *ManifestReleaseConfig) validate(name string) error {
 			return fmt.Errorf("invalid match regexp '%s'", m)
 		}
 	}
+	for idx := range c.Overrides {
+		if err := c.Overrides[idx].validate(); err != nil {
+			return err
+		}
+	}
+	if c.DetectVersions < 0 {
+		return fmt.Errorf("invalid negative detect_versions")
+	}
+	if c.InferOps && len(c.OpsDir) == 0 {
+		return fmt.Errorf("infer_ops requires ops_dir")
+	}
+	c.breakingRegexps = nil
+	for _, p := range c.BreakingPatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return fmt.Errorf("invalid breaking pattern regexp '%s'", p)
+		}
+		c.breakingRegexps = append(c.breakingRegexps, re)
+	}
 	// if 0 == len(c.Manifest) {
 	// 	return fmt.Errorf("missing mandatory manifest")
 	// }
 	return nil
 }
 
+// GithubAppConfig -
+type GithubAppConfig struct {
+	AppID          int64  `yaml:"app_id"`
+	InstallationID int64  `yaml:"installation_id"`
+	PrivateKey     string `yaml:"private_key"`
+}
+
+func (c *GithubAppConfig) validate(offline bool) error {
+	if c.AppID == 0 {
+		return fmt.Errorf("missing mandatory app_id")
+	}
+	if c.InstallationID == 0 {
+		return fmt.Errorf("missing mandatory installation_id")
+	}
+	if len(c.PrivateKey) == 0 {
+		return fmt.Errorf("missing mandatory private_key")
+	}
+	if offline {
+		return nil
+	}
+	val, err := os.ReadFile(c.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("unable to read file at path %s", c.PrivateKey)
+	}
+	c.PrivateKey = string(val)
+	return nil
+}
+
 // GithubConfig -
 type GithubConfig struct {
 	UpdateInterval   string                            `yaml:"update_interval"`
 	Token            string                            `yaml:"token"`
+	App              *GithubAppConfig                  `yaml:"app"`
+	CacheDir         string                            `yaml:"cache_dir"`
 	ManifestReleases map[string]*ManifestReleaseConfig `yaml:"manifest_releases"`
 	GenericReleases  map[string]*GenericReleaseConfig  `yaml:"generic_releases"`
 }
 
-func (c *GithubConfig) validate() error {
-	for name, data := range c.ManifestReleases {
-		if err := data.validate(name); err != nil {
-			return fmt.Errorf("invalid manifest release '%s', %s", name, err)
+// validate - Validates each release independently, giving all issues found
+//
+// Offline, credential files are not read.
+func (c *GithubConfig) validate(offline bool) []error {
+	errs := []error{}
+	for _, name := range sortedNames(c.ManifestReleases) {
+		if err := c.ManifestReleases[name].validate(name); err != nil {
+			errs = append(errs, fmt.Errorf("invalid manifest release '%s', %s", name, err))
 		}
 	}
-	for name, data := range c.GenericReleases {
-		if err := data.validate(name); err != nil {
-			return fmt.Errorf("invalid generic release '%s', %s", name, err)
+	for _, name := range sortedNames(c.GenericReleases) {
+		if err := c.GenericReleases[name].validate(name); err != nil {
+			errs = append(errs, fmt.Errorf("invalid generic release '%s', %s", name, err))
 		}
 	}
-	if len(c.Token) == 0 {
-		return fmt.Errorf("missing mandatory github token")
+	if c.App != nil {
+		if len(c.Token) != 0 {
+			errs = append(errs, fmt.Errorf("token and app authentications are mutually exclusive"))
+		} else if err := c.App.validate(offline); err != nil {
+			errs = append(errs, fmt.Errorf("invalid github app, %s", err))
+		}
 	}
 	_, err := time.ParseDuration(c.UpdateInterval)
 	if err != nil {
-		return fmt.Errorf("invalid duration format for update_interval")
+		errs = append(errs, fmt.Errorf("invalid duration format for update_interval"))
 	}
 
-	return nil
+	return errs
+}
+
+// sortedNames - Gives keys of given releases in alphabetical order
+func sortedNames[T any](releases map[string]T) []string {
+	res := []string{}
+	for name := range releases {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// IsAnonymous - Tells if github API should be queried without authentication
+func (c *GithubConfig) IsAnonymous() bool {
+	return len(c.Token) == 0 && c.App == nil
+}
+
+// CanDetectVersion - Tells if version of given deployment can be inferred from its bosh releases
+func (c *GithubConfig) CanDetectVersion(name string) bool {
+	for _, m := range c.ManifestReleases {
+		if m.DetectVersions > 0 && m.Match(name) {
+			return true
+		}
+	}
+	return false
 }
 
 // LogConfig -
@@ -147,36 +301,76 @@ type Config struct {
 	Log    LogConfig    `yaml:"log"`
 	Bosh   BoshConfig   `yaml:"bosh"`
 	Github GithubConfig `yaml:"github"`
+	// sources are not queried, data being read from a snapshot
+	offline bool
 }
 
 // Validate - Validate configuration object
 func (c *Config) Validate() error {
-	if err := c.Github.validate(); err != nil {
-		return fmt.Errorf("invalid github configuration: %s", err)
-	}
-	if err := c.Bosh.validate(); err != nil {
-		return fmt.Errorf("invalid bosh configuration: %s", err)
+	if errs := c.ValidateAll(); len(errs) != 0 {
+		return errs[0]
 	}
 	return nil
 }
 
-// NewConfig - Creates and validates config from given reader
-func NewConfig(file io.Reader) *Config {
+// ValidateAll - Validate configuration object, giving all issues found
+func (c *Config) ValidateAll() []error {
+	errs := []error{}
+	for _, err := range c.Github.validate(c.offline) {
+		errs = append(errs, fmt.Errorf("invalid github configuration: %s", err))
+	}
+	if err := c.Bosh.validate(c.offline); err != nil {
+		errs = append(errs, fmt.Errorf("invalid bosh configuration: %s", err))
+	}
+	if len(c.Bosh.Source) != 0 {
+		_, isManifest := c.Github.ManifestReleases[c.Bosh.Source]
+		_, isGeneric := c.Github.GenericReleases[c.Bosh.Source]
+		if !isManifest && !isGeneric {
+			errs = append(errs, fmt.Errorf("invalid bosh configuration: unknown director_source release '%s'", c.Bosh.Source))
+		}
+	}
+	return errs
+}
+
+// ParseConfig - Reads config from given reader, without validating it
+func ParseConfig(file io.Reader) (*Config, error) {
 	content, err := io.ReadAll(file)
 	if err != nil {
-		log.Fatalf("unable to read configuration file : %s", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("unable to read configuration file : %s", err)
 	}
 	config := Config{}
 	if err = yaml.Unmarshal(content, &config); err != nil {
 		if err = json.Unmarshal(content, &config); err != nil {
-			log.Fatalf("unable to read configuration json/xml file: %s", err)
-			os.Exit(1)
+			return nil, fmt.Errorf("unable to read configuration json/xml file: %s", err)
 		}
 	}
+	return &config, nil
+}
+
+// NewConfig - Creates and validates config from given reader
+func NewConfig(file io.Reader) *Config {
+	return newConfig(file, false)
+}
+
+// NewOfflineConfig - Creates and validates config used to read a snapshot
+//
+// Credential files of sources (CA certificate, jumpbox and GitHub App private keys)
+// are not read, snapshot can be read on a machine lacking them. Managers created
+// with this config never query GitHub.
+func NewOfflineConfig(file io.Reader) *Config {
+	return newConfig(file, true)
+}
+
+func newConfig(file io.Reader, offline bool) *Config {
+	config, err := ParseConfig(file)
+	if err != nil {
+		log.Fatalf("%s", err)
+		os.Exit(1)
+	}
+	config.offline = offline
 	if err = config.Validate(); err != nil {
 		log.Fatalf("invalid configuration, %s", err)
 		os.Exit(1)
 	}
-	return &config
+	return config
 }
@@ -0,0 +1,89 @@
+package boshupdate
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DetectionManifest - Version read from manifest_version key of deployment manifest
+	DetectionManifest = "manifest_version"
+	// DetectionReleases - Version inferred from bosh releases of deployment
+	DetectionReleases = "releases"
+)
+
+// DetectVersion - Infers manifest version of given deployment
+//
+// Bosh releases of the deployment are compared to releases rendered for each of the
+// recent versions of matching manifest releases, as configured by detect_versions.
+// Confidence is the Jaccard index of both name/version sets, newest version wins on tie.
+func (a *Manager) DetectVersion(deployment *BoshDeploymentData, manifests []ManifestReleaseData) error {
+	entry := log.WithField("deployment", deployment.Deployment)
+	entry.Debugf("detecting deployment version from bosh releases")
+
+	bestName := ""
+	bestVersion := ""
+	bestConfidence := 0.0
+	for _, m := range manifests {
+		if m.HasError || m.DetectVersions == 0 || len(m.Manifest) == 0 || !m.Match(deployment.ManifestName) {
+			continue
+		}
+		for idx, v := range m.Versions {
+			if idx >= m.DetectVersions {
+				break
+			}
+			releases, err := a.renderBoshReleases(atVersion(m, v), nil)
+			if err != nil {
+				entry.Debugf("ignoring version '%s' of manifest release '%s': %s", v.Version, m.Name, err)
+				continue
+			}
+			confidence := releasesSimilarity(releases, deployment.BoshReleases)
+			if confidence > bestConfidence {
+				bestName, bestVersion, bestConfidence = m.Name, v.Version, confidence
+			}
+		}
+	}
+
+	if bestConfidence == 0 {
+		return fmt.Errorf("unable to detect version of deployment '%s'", deployment.Deployment)
+	}
+
+	entry.Debugf("detected version '%s' of manifest release '%s' with confidence %.2f", bestVersion, bestName, bestConfidence)
+	deployment.ManifestName = bestName
+	deployment.Ref = bestVersion
+	deployment.Detection = DetectionReleases
+	deployment.Confidence = bestConfidence
+	return nil
+}
+
+// atVersion - Gives a copy of manifest release data targeting given version
+func atVersion(item ManifestReleaseData, version Version) ManifestReleaseData {
+	item.LatestVersion = version
+	return item
+}
+
+// releasesSimilarity - Jaccard index of name/version sets of given releases
+func releasesSimilarity(rendered []BoshRelease, deployed []BoshRelease) float64 {
+	set := map[string]bool{}
+	for _, r := range rendered {
+		set[r.Name+"/"+r.Version] = true
+	}
+	common := 0
+	union := len(set)
+	for _, r := range deployed {
+		if set[r.Name+"/"+r.Version] {
+			common++
+		} else {
+			union++
+		}
+	}
+	if union == 0 {
+		return 0
+	}
+	return float64(common) / float64(union)
+}
+
+// Local Variables:
+// ispell-local-dictionary: "american"
+// End:
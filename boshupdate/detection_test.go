@@ -0,0 +1,51 @@
+package boshupdate
+
+import (
+	"testing"
+)
+
+func TestReleasesSimilarity(t *testing.T) {
+	tests := []struct {
+		name     string
+		rendered []BoshRelease
+		deployed []BoshRelease
+		expected float64
+	}{
+		{
+			name:     "identical",
+			rendered: []BoshRelease{{Name: "capi", Version: "1.0"}, {Name: "diego", Version: "2.0"}},
+			deployed: []BoshRelease{{Name: "diego", Version: "2.0"}, {Name: "capi", Version: "1.0"}},
+			expected: 1,
+		},
+		{
+			name:     "different version",
+			rendered: []BoshRelease{{Name: "capi", Version: "1.0"}, {Name: "diego", Version: "2.0"}},
+			deployed: []BoshRelease{{Name: "capi", Version: "1.0"}, {Name: "diego", Version: "2.1"}},
+			expected: 1.0 / 3.0,
+		},
+		{
+			name:     "additional release",
+			rendered: []BoshRelease{{Name: "capi", Version: "1.0"}},
+			deployed: []BoshRelease{{Name: "capi", Version: "1.0"}, {Name: "custom", Version: "0.1"}},
+			expected: 0.5,
+		},
+		{
+			name:     "disjoint",
+			rendered: []BoshRelease{{Name: "capi", Version: "1.0"}},
+			deployed: []BoshRelease{{Name: "diego", Version: "2.0"}},
+			expected: 0,
+		},
+		{
+			name:     "empty",
+			rendered: []BoshRelease{},
+			deployed: []BoshRelease{},
+			expected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		if res := releasesSimilarity(tt.rendered, tt.deployed); res != tt.expected {
+			t.Errorf("%s: expected similarity %f, got %f", tt.name, tt.expected, res)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package boshupdate
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	// github repository of bosh releases hosted on bosh.io or github
+	releaseRepoRegex = regexp.MustCompile(`github\.com/([^/]+)/([^/?#]+)`)
+)
+
+// ReleaseBump - Bosh release whose version changed between two manifest versions
+type ReleaseBump struct {
+	Name  string `json:"name" yaml:"name"`
+	From  string `json:"from" yaml:"from"`
+	To    string `json:"to" yaml:"to"`
+	Notes string `json:"notes,omitempty" yaml:"notes,omitempty"`
+}
+
+// StemcellChange - Stemcell whose os or version changed between two manifest versions,
+// empty when added or removed
+type StemcellChange struct {
+	Alias string `json:"alias" yaml:"alias"`
+	From  string `json:"from" yaml:"from"`
+	To    string `json:"to" yaml:"to"`
+}
+
+// ManifestDiff - Changes of bosh releases and stemcells between two manifest versions
+type ManifestDiff struct {
+	Name      string           `json:"name" yaml:"name"`
+	From      string           `json:"from" yaml:"from"`
+	To        string           `json:"to" yaml:"to"`
+	Added     []BoshRelease    `json:"added" yaml:"added"`
+	Removed   []BoshRelease    `json:"removed" yaml:"removed"`
+	Bumped    []ReleaseBump    `json:"bumped" yaml:"bumped"`
+	Stemcells []StemcellChange `json:"stemcells" yaml:"stemcells"`
+}
+
+// FindManifestVersion - Gives version of manifest release matching either version or git reference
+func FindManifestVersion(item ManifestReleaseData, version string) (*Version, error) {
+	for idx := range item.Versions {
+		v := item.Versions[idx]
+		if v.Version == version || v.GitRef == version {
+			return &v, nil
+		}
+	}
+	return nil, fmt.Errorf("unable to find version '%s' of manifest release '%s'", version, item.Name)
+}
+
+// renderManifestAt - Renders given version of manifest release with configured ops-files and vars-files
+func (a *Manager) renderManifestAt(item ManifestReleaseData, version Version) (*BoshManifest, error) {
+	item = atVersion(item, version)
+	content, err := a.getContent(version.GitRef, item.ManifestReleaseConfig, item.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	final, err := a.RenderManifestWithVars(content, item, boshtpl.StaticVariables{})
+	if err != nil {
+		return nil, err
+	}
+	var manifest BoshManifest
+	if err = yaml.Unmarshal(final, &manifest); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse manifest '%s'", item.Manifest)
+	}
+	return &manifest, nil
+}
+
+// releaseNotes - Gives url of GitHub release notes of bosh release version, empty when
+// release is not hosted on GitHub or has no such release
+func (a *Manager) releaseNotes(release BoshRelease) string {
+	m := releaseRepoRegex.FindStringSubmatch(release.URL)
+	if m == nil {
+		return ""
+	}
+	for _, tag := range []string{"v" + release.Version, release.Version} {
+		r, _, err := a.client.Repositories.GetReleaseByTag(a.ctx, m[1], m[2], tag)
+		if err == nil {
+			return r.GetHTMLURL()
+		}
+	}
+	log.Debugf("unable to find release notes of '%s' version '%s'", release.Name, release.Version)
+	return ""
+}
+
+// DiffManifest - Compares bosh releases and stemcells of two versions of a manifest release
+//
+// Both versions are rendered with configured ops-files and vars-files. Stemcells are
+// compared by alias.
+func (a *Manager) DiffManifest(item ManifestReleaseData, from string, to string) (*ManifestDiff, error) {
+	if len(item.Manifest) == 0 {
+		return nil, fmt.Errorf("manifest release '%s' has no manifest", item.Name)
+	}
+	fromVersion, err := FindManifestVersion(item, from)
+	if err != nil {
+		return nil, err
+	}
+	toVersion, err := FindManifestVersion(item, to)
+	if err != nil {
+		return nil, err
+	}
+	fromManifest, err := a.renderManifestAt(item, *fromVersion)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to render version '%s'", fromVersion.Version)
+	}
+	toManifest, err := a.renderManifestAt(item, *toVersion)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to render version '%s'", toVersion.Version)
+	}
+
+	res := diffManifests(*fromManifest, *toManifest, a.releaseNotes)
+	res.Name = item.Name
+	res.From = fromVersion.Version
+	res.To = toVersion.Version
+	return res, nil
+}
+
+// diffManifests - Compares bosh releases and stemcells of two rendered manifests
+//
+// Bumped releases get the url of their release notes from given function. Stemcells
+// are sorted by alias.
+func diffManifests(from BoshManifest, to BoshManifest, notes func(BoshRelease) string) *ManifestDiff {
+	res := &ManifestDiff{
+		Added:     []BoshRelease{},
+		Removed:   []BoshRelease{},
+		Bumped:    []ReleaseBump{},
+		Stemcells: []StemcellChange{},
+	}
+
+	for _, br := range to.Releases {
+		old := findBoshRelease(from.Releases, br.Name)
+		switch {
+		case old == nil:
+			res.Added = append(res.Added, br)
+		case old.Version != br.Version:
+			res.Bumped = append(res.Bumped, ReleaseBump{
+				Name:  br.Name,
+				From:  old.Version,
+				To:    br.Version,
+				Notes: notes(br),
+			})
+		}
+	}
+	for _, br := range from.Releases {
+		if findBoshRelease(to.Releases, br.Name) == nil {
+			res.Removed = append(res.Removed, br)
+		}
+	}
+
+	fromStemcells := from.stemcellsByAlias()
+	toStemcells := to.stemcellsByAlias()
+	for alias, s := range toStemcells {
+		if old := fromStemcells[alias]; old != s {
+			res.Stemcells = append(res.Stemcells, StemcellChange{Alias: alias, From: old, To: s})
+		}
+	}
+	for alias, s := range fromStemcells {
+		if _, found := toStemcells[alias]; !found {
+			res.Stemcells = append(res.Stemcells, StemcellChange{Alias: alias, From: s})
+		}
+	}
+	sort.Slice(res.Stemcells, func(i, j int) bool {
+		return res.Stemcells[i].Alias < res.Stemcells[j].Alias
+	})
+	return res
+}
+
+// stemcellsByAlias - Gives os/version of stemcells, indexed by alias
+func (m BoshManifest) stemcellsByAlias() map[string]string {
+	res := map[string]string{}
+	for _, s := range m.Stemcells {
+		name := s.OS
+		if name == "" {
+			name = s.Name
+		}
+		res[s.Alias] = name + "/" + s.Version
+	}
+	return res
+}
+
+// Local Variables:
+// ispell-local-dictionary: "american"
+// End:
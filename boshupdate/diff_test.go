@@ -0,0 +1,144 @@
+package boshupdate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindManifestVersion(t *testing.T) {
+	item := ManifestReleaseData{
+		Name: "cf",
+		Versions: []Version{
+			{Version: "2.0.0", GitRef: "v2.0.0"},
+			{Version: "1.0.0", GitRef: "abcdef"},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		version       string
+		expected      string
+		expectedError bool
+	}{
+		{name: "by version", version: "2.0.0", expected: "2.0.0"},
+		{name: "by git reference", version: "abcdef", expected: "1.0.0"},
+		{name: "no match", version: "3.0.0", expectedError: true},
+		{name: "empty", version: "", expectedError: true},
+	}
+
+	for _, tt := range tests {
+		res, err := FindManifestVersion(item, tt.version)
+		if tt.expectedError {
+			if err == nil {
+				t.Errorf("%s: expected error, got version %s", tt.name, res.Version)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.name, err)
+			continue
+		}
+		if res.Version != tt.expected {
+			t.Errorf("%s: expected version %s, got %s", tt.name, tt.expected, res.Version)
+		}
+	}
+}
+
+func TestDiffManifests(t *testing.T) {
+	notes := func(r BoshRelease) string {
+		return "https://github.com/cloudfoundry/" + r.Name + "/releases/tag/v" + r.Version
+	}
+
+	tests := []struct {
+		name     string
+		from     BoshManifest
+		to       BoshManifest
+		expected *ManifestDiff
+	}{
+		{
+			name: "empty",
+			expected: &ManifestDiff{
+				Added:     []BoshRelease{},
+				Removed:   []BoshRelease{},
+				Bumped:    []ReleaseBump{},
+				Stemcells: []StemcellChange{},
+			},
+		},
+		{
+			name: "identical",
+			from: BoshManifest{
+				Releases:  []BoshRelease{{Name: "capi", Version: "1.0"}},
+				Stemcells: []BoshStemcell{{Alias: "default", OS: "ubuntu-jammy", Version: "1.12"}},
+			},
+			to: BoshManifest{
+				Releases:  []BoshRelease{{Name: "capi", Version: "1.0"}},
+				Stemcells: []BoshStemcell{{Alias: "default", OS: "ubuntu-jammy", Version: "1.12"}},
+			},
+			expected: &ManifestDiff{
+				Added:     []BoshRelease{},
+				Removed:   []BoshRelease{},
+				Bumped:    []ReleaseBump{},
+				Stemcells: []StemcellChange{},
+			},
+		},
+		{
+			name: "releases",
+			from: BoshManifest{
+				Releases: []BoshRelease{
+					{Name: "capi", Version: "1.0"},
+					{Name: "diego", Version: "2.0"},
+					{Name: "nats", Version: "3.0"},
+				},
+			},
+			to: BoshManifest{
+				Releases: []BoshRelease{
+					{Name: "capi", Version: "1.1"},
+					{Name: "diego", Version: "2.0"},
+					{Name: "routing", Version: "0.1"},
+				},
+			},
+			expected: &ManifestDiff{
+				Added:   []BoshRelease{{Name: "routing", Version: "0.1"}},
+				Removed: []BoshRelease{{Name: "nats", Version: "3.0"}},
+				Bumped: []ReleaseBump{
+					{Name: "capi", From: "1.0", To: "1.1", Notes: "https://github.com/cloudfoundry/capi/releases/tag/v1.1"},
+				},
+				Stemcells: []StemcellChange{},
+			},
+		},
+		{
+			name: "stemcells",
+			from: BoshManifest{
+				Stemcells: []BoshStemcell{
+					{Alias: "default", OS: "ubuntu-bionic", Version: "621.94"},
+					{Alias: "windows", OS: "windows2019", Version: "2019.40"},
+					{Alias: "legacy", Name: "bosh-warden-boshlite-ubuntu-trusty-go_agent", Version: "3586"},
+				},
+			},
+			to: BoshManifest{
+				Stemcells: []BoshStemcell{
+					{Alias: "default", OS: "ubuntu-jammy", Version: "1.12"},
+					{Alias: "windows", OS: "windows2019", Version: "2019.40"},
+					{Alias: "arm", OS: "ubuntu-jammy", Version: "1.12"},
+				},
+			},
+			expected: &ManifestDiff{
+				Added:   []BoshRelease{},
+				Removed: []BoshRelease{},
+				Bumped:  []ReleaseBump{},
+				Stemcells: []StemcellChange{
+					{Alias: "arm", From: "", To: "ubuntu-jammy/1.12"},
+					{Alias: "default", From: "ubuntu-bionic/621.94", To: "ubuntu-jammy/1.12"},
+					{Alias: "legacy", From: "bosh-warden-boshlite-ubuntu-trusty-go_agent/3586", To: ""},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		res := diffManifests(tt.from, tt.to, notes)
+		if !reflect.DeepEqual(res, tt.expected) {
+			t.Errorf("%s: unexpected diff:\n got: %+v\nwant: %+v", tt.name, res, tt.expected)
+		}
+	}
+}
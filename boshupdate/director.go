@@ -0,0 +1,237 @@
+package boshupdate
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/bosh-cli/director"
+	"github.com/cloudfoundry/bosh-cli/uaa"
+)
+
+const (
+	// delay before first reconnection attempt, doubled on each failure
+	directorMinBackoff = 5 * time.Second
+	// renew UAA tokens this long before they expire
+	tokenExpiryMargin = 60 * time.Second
+)
+
+// tokenSession - Fetches UAA client tokens and renews them before expiry
+//
+// uaa.ClientTokenSession only renews token once director rejected it.
+type tokenSession struct {
+	uaa    uaa.Client
+	mutex  sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// TokenFunc - Implements director.FactoryConfig TokenFunc
+func (s *tokenSession) TokenFunc(retried bool) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.token == "" || retried || time.Now().Add(tokenExpiryMargin).After(s.expiry) {
+		resp, err := s.uaa.ClientCredentialsGrant()
+		if err != nil {
+			return "", err
+		}
+		s.token = resp.Type + " " + resp.AccessToken
+		// opaque tokens are renewed once rejected by the director
+		s.expiry = time.Now().Add(time.Hour)
+		if info, err := uaa.NewTokenInfoFromValue(resp.AccessToken); err == nil {
+			s.expiry = time.Unix(int64(info.ExpiredAt), 0)
+		}
+	}
+	return s.token, nil
+}
+
+// directorStatus - Extracts HTTP status from errors of requests the director answered
+var directorStatus = regexp.MustCompile(`Director responded with non-successful status code '(\d+)'`)
+
+// isUnavailable - Tells if request failed because director could not serve it
+//
+// bosh-utils flattens transport errors into plain strings, only answers of the
+// director can be told apart. Client errors don't mean the director is down.
+func isUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	match := directorStatus.FindStringSubmatch(err.Error())
+	if match == nil {
+		return true
+	}
+	status, _ := strconv.Atoi(match[1])
+	return status >= 500
+}
+
+// boshDirector - Director client connecting lazily with exponential backoff
+//
+// Connection is attempted on first request. Once director is found unavailable,
+// either on connection or on request, requests fail immediately until next attempt
+// is due, avoiding to hammer a director under maintenance.
+//
+// Requests are bounded by the connection deadline set by the dialer, see withDeadline.
+type boshDirector struct {
+	config  BoshConfig
+	connect sync.Mutex
+	mutex   sync.Mutex
+	client  *DirectorClient
+	up      bool
+	backoff time.Duration
+	retryAt time.Time
+}
+
+func newBoshDirector(config BoshConfig) *boshDirector {
+	return &boshDirector{
+		config: config,
+	}
+}
+
+// IsUp - Tells if last request to director succeeded
+func (d *boshDirector) IsUp() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.up
+}
+
+// current - Gives connected client, nil if none, or an error while backing off
+func (d *boshDirector) current() (*DirectorClient, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if time.Now().Before(d.retryAt) {
+		return nil, fmt.Errorf("director unavailable, next attempt at %s", d.retryAt.Format(time.RFC3339))
+	}
+	return d.client, nil
+}
+
+func (d *boshDirector) get() (*DirectorClient, error) {
+	if client, err := d.current(); client != nil || err != nil {
+		return client, err
+	}
+
+	// only one connection attempt at a time, state lock is not held while dialing
+	d.connect.Lock()
+	defer d.connect.Unlock()
+	if client, err := d.current(); client != nil || err != nil {
+		return client, err
+	}
+
+	client, err := NewDirector(d.config)
+	if err != nil {
+		d.report(err)
+		return nil, fmt.Errorf("unable to connect to director: %s", err)
+	}
+	d.mutex.Lock()
+	d.client = client
+	d.mutex.Unlock()
+	return client, nil
+}
+
+// report - Records request outcome, backing off when director is unavailable
+func (d *boshDirector) report(err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if !isUnavailable(err) {
+		d.up = true
+		d.backoff = 0
+		return
+	}
+	d.up = false
+	d.backoff = min(max(2*d.backoff, directorMinBackoff), d.config.RetryMaxBackoff())
+	d.retryAt = time.Now().Add(d.backoff)
+}
+
+// call - Runs request on connected director
+func call[T any](d *boshDirector, request func(*DirectorClient) (T, error)) (T, error) {
+	client, err := d.get()
+	if err != nil {
+		var empty T
+		return empty, err
+	}
+	res, err := request(client)
+	d.report(err)
+	return res, err
+}
+
+// Info -
+func (d *boshDirector) Info() (director.Info, error) {
+	return call(d, (*DirectorClient).Info)
+}
+
+// Deployments -
+func (d *boshDirector) Deployments() ([]*boshDeployment, error) {
+	deployments, err := call(d, (*DirectorClient).Deployments)
+	res := []*boshDeployment{}
+	for _, deployment := range deployments {
+		res = append(res, &boshDeployment{DeploymentResp: deployment, director: d})
+	}
+	return res, err
+}
+
+// FindDeployment - Gives deployment of given name, without its releases, stemcells and teams
+func (d *boshDirector) FindDeployment(name string) *boshDeployment {
+	return &boshDeployment{DeploymentResp: director.DeploymentResp{Name: name}, director: d}
+}
+
+// ListDeployments -
+func (d *boshDirector) ListDeployments() ([]director.DeploymentResp, error) {
+	return call(d, (*DirectorClient).ListDeployments)
+}
+
+// Releases -
+func (d *boshDirector) Releases() ([]director.ReleaseSeriesResp, error) {
+	return call(d, (*DirectorClient).Releases)
+}
+
+// Stemcells -
+func (d *boshDirector) Stemcells() ([]director.StemcellResp, error) {
+	return call(d, (*DirectorClient).Stemcells)
+}
+
+// ListConfigs -
+func (d *boshDirector) ListConfigs(limit int, filter director.ConfigsFilter) ([]director.Config, error) {
+	return call(d, func(c *DirectorClient) ([]director.Config, error) {
+		return c.ListConfigs(limit, filter)
+	})
+}
+
+// RecentTasks -
+func (d *boshDirector) RecentTasks(limit int, filter director.TasksFilter) ([]director.TaskResp, error) {
+	return call(d, func(c *DirectorClient) ([]director.TaskResp, error) {
+		return c.RecentTasks(limit, filter)
+	})
+}
+
+// boshDeployment - Deployment as listed by director, whose requests are subject to director backoff
+type boshDeployment struct {
+	director.DeploymentResp
+	director *boshDirector
+}
+
+// Manifest -
+func (d *boshDeployment) Manifest() (string, error) {
+	return call(d.director, func(c *DirectorClient) (string, error) {
+		return c.Manifest(d.Name)
+	})
+}
+
+// InstanceInfos -
+func (d *boshDeployment) InstanceInfos() ([]director.VMInfo, error) {
+	return call(d.director, func(c *DirectorClient) ([]director.VMInfo, error) {
+		return c.InstanceInfos(d.Name)
+	})
+}
+
+// Variables -
+func (d *boshDeployment) Variables() ([]director.VariableResult, error) {
+	return call(d.director, func(c *DirectorClient) ([]director.VariableResult, error) {
+		return c.Variables(d.Name)
+	})
+}
+
+// Local Variables:
+// ispell-local-dictionary: "american"
+// End:
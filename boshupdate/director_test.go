@@ -0,0 +1,25 @@
+package boshupdate
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIsUnavailable(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "success", err: nil, expected: false},
+		{name: "network", err: errors.New("Performing GET request: dial tcp 10.0.0.1:25555: connect: connection refused"), expected: true},
+		{name: "not found", err: errors.New("Fetching manifest: Director responded with non-successful status code '404' response 'not found'"), expected: false},
+		{name: "gateway", err: errors.New("Director responded with non-successful status code '502' response ''"), expected: true},
+	}
+
+	for _, tt := range tests {
+		if res := isUnavailable(tt.err); res != tt.expected {
+			t.Errorf("%s: expected %t, got %t", tt.name, tt.expected, res)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package boshupdate
+
+import (
+	"regexp"
+)
+
+// Filter - Selects manifest releases, generic releases and deployments to process, nil
+// expressions select everything
+//
+// Releases are selected when expression matches either their name or their 'owner/repo'
+// GitHub repository. When manifest releases are filtered, deployments are also required
+// to match one of the selected manifest releases.
+type Filter struct {
+	Manifest   *regexp.Regexp
+	Generic    *regexp.Regexp
+	Deployment *regexp.Regexp
+}
+
+func (f Filter) matchManifest(name string, item GenericReleaseConfig) bool {
+	return matchRelease(f.Manifest, name, item)
+}
+
+func (f Filter) matchGeneric(name string, item GenericReleaseConfig) bool {
+	return matchRelease(f.Generic, name, item)
+}
+
+func (f Filter) matchDeployment(name string) bool {
+	return f.Deployment == nil || f.Deployment.MatchString(name)
+}
+
+func matchRelease(re *regexp.Regexp, name string, item GenericReleaseConfig) bool {
+	return re == nil || re.MatchString(name) || re.MatchString(item.Owner+"/"+item.Repo)
+}
+
+// Local Variables:
+// ispell-local-dictionary: "american"
+// End:
@@ -0,0 +1,64 @@
+package boshupdate
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestGetBoshDeploymentsFiltered(t *testing.T) {
+	manager := &Manager{
+		config: Config{
+			Github: GithubConfig{
+				ManifestReleases: map[string]*ManifestReleaseConfig{
+					"cf": {
+						GenericReleaseConfig: GenericReleaseConfig{Owner: "cloudfoundry", Repo: "cf-deployment"},
+						Matchers:             []string{"^cf$"},
+					},
+					"redis": {
+						GenericReleaseConfig: GenericReleaseConfig{Owner: "pivotal", Repo: "redis-boshrelease"},
+						Matchers:             []string{"^redis-.*$"},
+					},
+				},
+			},
+		},
+		snapshot: &Snapshot{
+			Deployments: []BoshDeploymentData{
+				{Deployment: "cf", ManifestName: "cf"},
+				{Deployment: "redis-a", ManifestName: "redis-a"},
+				{Deployment: "redis-b", ManifestName: "redis-b"},
+				{Deployment: "custom", ManifestName: "custom"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected []string
+	}{
+		{name: "no filter", filter: Filter{}, expected: []string{"cf", "redis-a", "redis-b", "custom"}},
+		{name: "manifest name", filter: Filter{Manifest: regexp.MustCompile("^redis$")}, expected: []string{"redis-a", "redis-b"}},
+		{name: "manifest repository", filter: Filter{Manifest: regexp.MustCompile("^cloudfoundry/")}, expected: []string{"cf"}},
+		{
+			name:     "manifest and deployment",
+			filter:   Filter{Manifest: regexp.MustCompile("^redis$"), Deployment: regexp.MustCompile("-b$")},
+			expected: []string{"redis-b"},
+		},
+		{name: "deployment only", filter: Filter{Deployment: regexp.MustCompile("^custom$")}, expected: []string{"custom"}},
+	}
+
+	for _, tt := range tests {
+		deployments, err := manager.GetBoshDeploymentsFiltered(tt.filter)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %s", tt.name, err)
+		}
+		res := []string{}
+		for _, d := range deployments {
+			res = append(res, d.Deployment)
+		}
+		if !reflect.DeepEqual(res, tt.expected) {
+			t.Errorf("%s: expected deployments %v, got %v", tt.name, tt.expected, res)
+		}
+	}
+}
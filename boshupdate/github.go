@@ -0,0 +1,292 @@
+package boshupdate
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// github refuses application JWT valid for more than 10 minutes
+	appJWTDuration = 9 * time.Minute
+	// tolerate small clock drifts between exporter and github
+	appJWTDrift = 60 * time.Second
+)
+
+// parsePrivateKey - Parse PEM encoded RSA private key, either PKCS1 or PKCS8
+func parsePrivateKey(content []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("unable to decode PEM private key")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse private key")
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is not a RSA key")
+	}
+	return rsaKey, nil
+}
+
+// appJWTSource - Generates short-lived JWT authenticating as the GitHub App itself
+type appJWTSource struct {
+	appID int64
+	key   *rsa.PrivateKey
+}
+
+// Token - Implements oauth2.TokenSource
+func (s *appJWTSource) Token() (*oauth2.Token, error) {
+	now := time.Now()
+	expiry := now.Add(appJWTDuration)
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]int64{
+		"iat": now.Add(-appJWTDrift).Unix(),
+		"exp": expiry.Unix(),
+		"iss": s.appID,
+	})
+
+	enc := base64.RawURLEncoding
+	payload := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(payload))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to sign github app JWT")
+	}
+
+	return &oauth2.Token{
+		AccessToken: payload + "." + enc.EncodeToString(signature),
+		TokenType:   "Bearer",
+		Expiry:      expiry,
+	}, nil
+}
+
+// installationTokenSource - Mints GitHub App installation tokens
+type installationTokenSource struct {
+	ctx            context.Context
+	client         *github.Client
+	installationID int64
+}
+
+// Token - Implements oauth2.TokenSource
+func (s *installationTokenSource) Token() (*oauth2.Token, error) {
+	token, _, err := s.client.Apps.CreateInstallationToken(s.ctx, s.installationID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to create installation token for installation %d", s.installationID)
+	}
+	return &oauth2.Token{
+		AccessToken: token.GetToken(),
+		TokenType:   "token",
+		Expiry:      token.GetExpiresAt(),
+	}, nil
+}
+
+// cacheEntry - Cached response, persisted as json when cache directory is configured
+type cacheEntry struct {
+	ETag   string      `json:"etag"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// cachingTransport - Replays cached GET responses using conditional requests
+//
+//  1. GitHub doesn't count '304 Not Modified' responses against the rate limit of
+//     authenticated requests. Unauthenticated ones are still counted, the cache then
+//     only saves bandwidth.
+//  2. Entries are kept in memory and, when dir is given, on disk so that one-shot
+//     runs of boshupdate_cli benefit from previous ones.
+type cachingTransport struct {
+	base    http.RoundTripper
+	dir     string
+	mutex   sync.Mutex
+	entries map[string]cacheEntry
+}
+
+func newCachingTransport(base http.RoundTripper, dir string) *cachingTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &cachingTransport{
+		base:    base,
+		dir:     dir,
+		entries: map[string]cacheEntry{},
+	}
+}
+
+// path - Gives file of cache entry for given key
+func (t *cachingTransport) path(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return filepath.Join(t.dir, hex.EncodeToString(hash[:])+".json")
+}
+
+// load - Gives cache entry from memory, from disk otherwise
+func (t *cachingTransport) load(key string) (cacheEntry, bool) {
+	t.mutex.Lock()
+	entry, found := t.entries[key]
+	t.mutex.Unlock()
+	if found || t.dir == "" {
+		return entry, found
+	}
+
+	content, err := os.ReadFile(t.path(key))
+	if err != nil {
+		return entry, false
+	}
+	if err = json.Unmarshal(content, &entry); err != nil {
+		log.Warnf("ignoring invalid github cache entry '%s': %s", t.path(key), err)
+		return entry, false
+	}
+	t.mutex.Lock()
+	t.entries[key] = entry
+	t.mutex.Unlock()
+	return entry, true
+}
+
+// store - Saves cache entry in memory and on disk, disk errors are only logged
+func (t *cachingTransport) store(key string, entry cacheEntry) {
+	t.mutex.Lock()
+	t.entries[key] = entry
+	t.mutex.Unlock()
+	if t.dir == "" {
+		return
+	}
+
+	content, _ := json.Marshal(entry)
+	if err := os.MkdirAll(t.dir, 0700); err != nil {
+		log.Warnf("unable to create github cache directory '%s': %s", t.dir, err)
+		return
+	}
+	if err := os.WriteFile(t.path(key), content, 0600); err != nil {
+		log.Warnf("unable to write github cache entry '%s': %s", t.path(key), err)
+	}
+}
+
+// RoundTrip - Implements http.RoundTripper
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.base.RoundTrip(req)
+	}
+
+	key := req.URL.String()
+	entry, found := t.load(key)
+	if found {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", entry.ETag)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if found && resp.StatusCode == http.StatusNotModified {
+		_ = resp.Body.Close()
+		header := entry.Header.Clone()
+		for k, v := range resp.Header {
+			header[k] = v
+		}
+		resp.StatusCode = http.StatusOK
+		resp.Status = http.StatusText(http.StatusOK)
+		resp.Header = header
+		resp.Body = io.NopCloser(bytes.NewReader(entry.Body))
+		resp.ContentLength = int64(len(entry.Body))
+		return resp, nil
+	}
+
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	t.store(key, cacheEntry{ETag: etag, Header: resp.Header.Clone(), Body: body})
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// checkRateLimit - Gives an explicit error when GitHub API quota is consumed
+func checkRateLimit(err error) error {
+	var rateErr *github.RateLimitError
+	if errors.As(err, &rateErr) {
+		return fmt.Errorf("github API rate limit of %d requests per hour is consumed, quota resets at %s",
+			rateErr.Rate.Limit, rateErr.Rate.Reset.Format(time.RFC3339))
+	}
+	return err
+}
+
+// offlineTransport - Refuses GitHub requests of managers reading a snapshot
+type offlineTransport struct{}
+
+// RoundTrip -
+func (offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, fmt.Errorf("unable to query '%s' while reading a snapshot", req.URL)
+}
+
+// newGithubHTTPClient - Creates http client authenticated according to given configuration
+//
+// Installation tokens are valid one hour, oauth2.ReuseTokenSource transparently mints
+// a new one when current token is about to expire. Conditional requests are sent below
+// authentication, 304 responses being then free of charge.
+func newGithubHTTPClient(ctx context.Context, config GithubConfig) (*http.Client, error) {
+	cache := newCachingTransport(nil, config.CacheDir)
+	if config.IsAnonymous() {
+		log.Warnf("no github credentials configured, using unauthenticated mode limited to 60 requests per hour")
+		log.Warnf("unauthenticated mode skips tag date lookups")
+		return &http.Client{Transport: cache}, nil
+	}
+
+	if config.App == nil {
+		ts := oauth2.StaticTokenSource(
+			&oauth2.Token{AccessToken: config.Token},
+		)
+		return &http.Client{Transport: &oauth2.Transport{Source: ts, Base: cache}}, nil
+	}
+
+	key, err := parsePrivateKey([]byte(config.App.PrivateKey))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid github app private key")
+	}
+	jwt := oauth2.ReuseTokenSource(nil, &appJWTSource{
+		appID: config.App.AppID,
+		key:   key,
+	})
+	its := &installationTokenSource{
+		ctx:            ctx,
+		client:         github.NewClient(oauth2.NewClient(ctx, jwt)),
+		installationID: config.App.InstallationID,
+	}
+	return &http.Client{Transport: &oauth2.Transport{Source: oauth2.ReuseTokenSource(nil, its), Base: cache}}, nil
+}
+
+// Local Variables:
+// ispell-local-dictionary: "american"
+// End:
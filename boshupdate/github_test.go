@@ -0,0 +1,63 @@
+package boshupdate
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCachingTransportRevalidation(t *testing.T) {
+	requests := 0
+	notModified := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"tag":"v1"}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	get := func(transport *cachingTransport) (int, string, string) {
+		client := http.Client{Transport: transport}
+		resp, err := client.Get(server.URL + "/repos/o/r/releases")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, resp.Header.Get("Content-Type"), string(body)
+	}
+
+	tests := []struct {
+		name        string
+		transport   *cachingTransport
+		notModified int
+	}{
+		{"first request", newCachingTransport(nil, dir), 0},
+		{"revalidated from memory", nil, 1},
+		{"revalidated from disk", newCachingTransport(nil, dir), 2},
+	}
+	var transport *cachingTransport
+	for _, tt := range tests {
+		if tt.transport != nil {
+			transport = tt.transport
+		}
+		status, contentType, body := get(transport)
+		if status != http.StatusOK || contentType != "application/json" || body != `{"tag":"v1"}` {
+			t.Errorf("%s: unexpected response %d %s %s", tt.name, status, contentType, body)
+		}
+		if notModified != tt.notModified {
+			t.Errorf("%s: expected %d revalidations, got %d", tt.name, tt.notModified, notModified)
+		}
+	}
+	if requests != 3 {
+		t.Errorf("expected 3 requests, got %d", requests)
+	}
+}
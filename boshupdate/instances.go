@@ -0,0 +1,156 @@
+package boshupdate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudfoundry/bosh-cli/director"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// deploymentLayout - Instance groups, stemcells and releases declared by a deployment manifest
+type deploymentLayout struct {
+	Releases       []BoshRelease  `yaml:"releases"`
+	Stemcells      []BoshStemcell `yaml:"stemcells"`
+	InstanceGroups []struct {
+		Name     string `yaml:"name"`
+		Stemcell string `yaml:"stemcell"`
+		Jobs     []struct {
+			Name    string `yaml:"name"`
+			Release string `yaml:"release"`
+		} `yaml:"jobs"`
+	} `yaml:"instance_groups"`
+}
+
+// failingStates - Process states of instances that should run but don't, instances
+// stopped on purpose or running errands are not failing
+var failingStates = map[string]bool{
+	"failing":            true,
+	"unresponsive agent": true,
+}
+
+// countInstances - Counts instances of each instance group by running stemcell, and
+// failing instances of each instance group
+//
+// Instances without VM, such as stopped errands, are ignored.
+func countInstances(infos []director.VMInfo) (map[string]map[string]int, map[string]int) {
+	running := map[string]map[string]int{}
+	failing := map[string]int{}
+	for _, info := range infos {
+		if info.VMID == "" {
+			continue
+		}
+		if _, found := running[info.JobName]; !found {
+			running[info.JobName] = map[string]int{}
+		}
+		running[info.JobName][info.Stemcell.Name+"/"+info.Stemcell.Version]++
+		if failingStates[info.ProcessState] {
+			failing[info.JobName]++
+		}
+	}
+	return running, failing
+}
+
+// getInstanceGroups - Compares running state of deployment VMs with its deployed manifest
+//
+//  1. Manifest may give stemcell os instead of name, running stemcell names contain the os.
+//  2. Stemcells declared as 'latest' can't be compared to running ones, nor stemcells of
+//     instance groups whose alias isn't declared.
+//  3. Releases bound to the deployment by the director must match the manifest ones.
+//  4. The director API doesn't tell which release versions run on VMs. Releases of instance
+//     groups are those declared by the deployed manifest, a partially failed deploy is
+//     detected from the state of the last deploy task and of VM processes instead, see
+//     failingStates.
+func (a *Manager) getInstanceGroups(deployment *boshDeployment, manifest string) ([]InstanceGroupData, []string, error) {
+	var layout deploymentLayout
+	if err := yaml.Unmarshal([]byte(manifest), &layout); err != nil {
+		return nil, nil, errors.Wrapf(err, "unable to parse manifest")
+	}
+
+	infos, err := deployment.InstanceInfos()
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "unable to fetch instances")
+	}
+	running, failing := countInstances(infos)
+
+	drifts := []string{}
+	versions := map[string]string{}
+	for _, r := range layout.Releases {
+		versions[r.Name] = r.Version
+	}
+
+	res := []InstanceGroupData{}
+	for _, ig := range layout.InstanceGroups {
+		data := InstanceGroupData{
+			Name:      ig.Name,
+			Failing:   failing[ig.Name],
+			Stemcells: map[string]int{},
+			Releases:  []BoshRelease{},
+		}
+		for _, s := range layout.Stemcells {
+			if s.Alias == ig.Stemcell {
+				data.ExpectedStemcell = s.Version
+				data.expectedName = s.Name
+				if data.expectedName == "" {
+					data.expectedName = s.OS
+				}
+			}
+		}
+		seen := map[string]bool{}
+		for _, j := range ig.Jobs {
+			if seen[j.Release] {
+				continue
+			}
+			seen[j.Release] = true
+			data.Releases = append(data.Releases, BoshRelease{Name: j.Release, Version: versions[j.Release]})
+		}
+		sort.Slice(data.Releases, func(i, j int) bool {
+			return data.Releases[i].Name < data.Releases[j].Name
+		})
+
+		for stemcell, count := range running[ig.Name] {
+			data.Stemcells[stemcell] = count
+			data.Instances += count
+			name, version, _ := strings.Cut(stemcell, "/")
+			// 1. 2.
+			if data.ExpectedStemcell == "" || data.ExpectedStemcell == "latest" {
+				continue
+			}
+			if version != data.ExpectedStemcell || !strings.Contains(name, data.expectedName) {
+				data.HasDrift = true
+			}
+		}
+		if data.HasDrift {
+			drifts = append(drifts, fmt.Sprintf("instance group '%s' runs unexpected stemcells", ig.Name))
+		}
+		// 4.
+		if data.Failing != 0 {
+			data.HasDrift = true
+			drifts = append(drifts, fmt.Sprintf("instance group '%s' has %d failing instances", ig.Name, data.Failing))
+		}
+		res = append(res, data)
+	}
+
+	// 3.
+	for _, r := range deployment.Releases {
+		if v, found := versions[r.Name]; found && v != "latest" && v != r.Version {
+			drifts = append(drifts, fmt.Sprintf("release '%s' is bound at version '%s' instead of '%s'", r.Name, r.Version, v))
+		}
+	}
+
+	// 4.
+	task, err := a.lastDeployTask(deployment.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if task != nil && task.State != "done" && task.State != "processing" && task.State != "queued" {
+		drifts = append(drifts, fmt.Sprintf("last deploy task %d ended in state '%s', instances may run previous releases", task.ID, task.State))
+	}
+	return res, drifts, nil
+}
+
+// Local Variables:
+// ispell-local-dictionary: "american"
+// End:
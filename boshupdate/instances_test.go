@@ -0,0 +1,68 @@
+package boshupdate
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cloudfoundry/bosh-cli/director"
+)
+
+func TestCountInstances(t *testing.T) {
+	instance := func(group string, vm string, state string) director.VMInfo {
+		return director.VMInfo{
+			JobName:      group,
+			VMID:         vm,
+			ProcessState: state,
+			Stemcell:     director.VmInfoStemcell{Name: "ubuntu-jammy", Version: "1.0"},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		infos     []director.VMInfo
+		instances map[string]int
+		failing   map[string]int
+	}{
+		{name: "no instance", infos: []director.VMInfo{}, instances: map[string]int{}, failing: map[string]int{}},
+		{
+			name:      "running",
+			infos:     []director.VMInfo{instance("api", "vm-1", "running"), instance("api", "vm-2", "running")},
+			instances: map[string]int{"api": 2},
+			failing:   map[string]int{},
+		},
+		{
+			name:      "stopped on purpose",
+			infos:     []director.VMInfo{instance("api", "vm-1", "stopped"), instance("api", "vm-2", "starting")},
+			instances: map[string]int{"api": 2},
+			failing:   map[string]int{},
+		},
+		{
+			name:      "errand without vm",
+			infos:     []director.VMInfo{instance("smoke-tests", "", "")},
+			instances: map[string]int{},
+			failing:   map[string]int{},
+		},
+		{
+			name:      "failing",
+			infos:     []director.VMInfo{instance("api", "vm-1", "failing"), instance("router", "vm-2", "unresponsive agent"), instance("router", "vm-3", "running")},
+			instances: map[string]int{"api": 1, "router": 2},
+			failing:   map[string]int{"api": 1, "router": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		running, failing := countInstances(tt.infos)
+		instances := map[string]int{}
+		for group, stemcells := range running {
+			for _, count := range stemcells {
+				instances[group] += count
+			}
+		}
+		if !reflect.DeepEqual(instances, tt.instances) {
+			t.Errorf("%s: expected instances %v, got %v", tt.name, tt.instances, instances)
+		}
+		if !reflect.DeepEqual(failing, tt.failing) {
+			t.Errorf("%s: expected failing instances %v, got %v", tt.name, tt.failing, failing)
+		}
+	}
+}
@@ -4,8 +4,12 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
+	"path"
 	"regexp"
 	"sort"
+	"strings"
+	"sync"
 
 	"github.com/Masterminds/semver"
 	"github.com/cloudfoundry/bosh-cli/director"
@@ -15,114 +19,314 @@ import (
 	"github.com/orange-cloudfoundry/boshupdate_exporter/utils"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
-	"golang.org/x/oauth2"
 	"gopkg.in/yaml.v2"
 )
 
 // Manager -
 type Manager struct {
-	config   Config
-	client   *github.Client
-	ctx      context.Context
-	director director.Director
+	config      Config
+	client      *github.Client
+	http        *http.Client
+	ctx         context.Context
+	director    *boshDirector
+	mutex       sync.Mutex
+	renderCache map[string][]BoshRelease
+	// github file contents, keyed by owner/repo@ref|path
+	contentCache map[string][]byte
+	// ops-files of ops_dir, keyed by owner/repo@ref|dir
+	listCache   map[string]map[string]string
+	opsCache    map[string]*OpsAnalysis
+	unusedSince map[string]int64
+	// deployment manifests, keyed by deployment name
+	manifestCache map[string]*manifestEntry
+	manifestStats CacheStats
+	// when set, data is served from snapshot without network access
+	snapshot *Snapshot
 }
 
 // NewManager -
 func NewManager(config Config) (*Manager, error) {
 	ctx := context.Background()
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: config.Github.Token},
-	)
-	tc := oauth2.NewClient(ctx, ts)
-	newDirector, err := NewDirector(config.Bosh)
-	if err != nil {
-		return nil, errors.Wrapf(err, "unable to create director client")
+	tc := &http.Client{Transport: offlineTransport{}}
+	if !config.offline {
+		var err error
+		tc, err = newGithubHTTPClient(ctx, config.Github)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to create github client")
+		}
 	}
-
 	return &Manager{
-		config:   config,
-		client:   github.NewClient(tc),
-		ctx:      ctx,
-		director: newDirector,
+		config:        config,
+		client:        github.NewClient(tc),
+		http:          tc,
+		ctx:           ctx,
+		director:      newBoshDirector(config.Bosh),
+		renderCache:   map[string][]BoshRelease{},
+		contentCache:  map[string][]byte{},
+		listCache:     map[string]map[string]string{},
+		opsCache:      map[string]*OpsAnalysis{},
+		unusedSince:   map[string]int64{},
+		manifestCache: map[string]*manifestEntry{},
 	}, nil
 }
 
+// IsDirectorUp - Tells if last request to bosh director succeeded
+func (a *Manager) IsDirectorUp() bool {
+	if a.snapshot != nil {
+		return true
+	}
+	return a.director.IsUp()
+}
+
 // GetBoshDeployments -
 func (a *Manager) GetBoshDeployments() ([]BoshDeploymentData, error) {
+	return a.GetBoshDeploymentsFiltered(Filter{})
+}
+
+// GetBoshDeploymentsFiltered - Fetches deployments selected by filter
+//
+// When filter selects manifest releases, deployments matching none of them are dropped.
+func (a *Manager) GetBoshDeploymentsFiltered(filter Filter) ([]BoshDeploymentData, error) {
+	deployments, err := a.getBoshDeployments(filter)
+	if filter.Manifest == nil {
+		return deployments, err
+	}
+	res := []BoshDeploymentData{}
+	for _, d := range deployments {
+		if a.selectsManifest(filter, d.ManifestName) {
+			res = append(res, d)
+		}
+	}
+	return res, err
+}
+
+// selectsManifest - Tells if one of manifest releases selected by filter matches given manifest name
+func (a *Manager) selectsManifest(filter Filter, manifestName string) bool {
+	for name, item := range a.config.Github.ManifestReleases {
+		if filter.matchManifest(name, item.GenericReleaseConfig) && item.Match(manifestName) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Manager) getBoshDeployments(filter Filter) ([]BoshDeploymentData, error) {
 	entry := log.WithField("name", "deployments")
 	entry.Debugf("processing bosh deployments")
 
+	if a.snapshot != nil {
+		res := []BoshDeploymentData{}
+		for _, d := range a.snapshot.Deployments {
+			if filter.matchDeployment(d.Deployment) {
+				res = append(res, d)
+			}
+		}
+		return res, nil
+	}
+
 	res := []BoshDeploymentData{}
-	re := regexp.MustCompile("v(.*)")
 
 	deployments, err := a.director.Deployments()
 	if err != nil {
 		return res, errors.Wrapf(err, "unable to fetch deployments")
 	}
 
+	a.pruneManifests(deployments)
 	for _, deployment := range deployments {
-		entry.Debugf("processing bosh deployment %s", deployment.Name())
-		manifest, err := deployment.Manifest()
+		if !filter.matchDeployment(deployment.Name) {
+			continue
+		}
+		entry.Debugf("processing bosh deployment %s", deployment.Name)
+		cached, err := a.getManifest(deployment)
 		if err != nil {
-			log.Errorf("unable to fetch manifest for deployment '%s': %+v", deployment.Name(), err)
+			log.Errorf("unable to get manifest for deployment '%s': %+v", deployment.Name, err)
 			res = append(res, BoshDeploymentData{
-				Deployment: deployment.Name(),
+				Deployment: deployment.Name,
 				HasError:   true,
 			})
 			continue
 		}
 
+		manifest, doc := cached.manifest, cached.doc
 		data := struct {
-			Version  string        `yaml:"manifest_version"`
-			Name     string        `yaml:"manifest_name"`
-			Releases []BoshRelease `yaml:"releases"`
-		}{}
-		err = yaml.Unmarshal([]byte(manifest), &data)
-		if err != nil {
-			log.Errorf("unable to parse manifest for deployment '%s': %+v", deployment.Name(), err)
-			res = append(res, BoshDeploymentData{
-				Deployment: deployment.Name(),
-				HasError:   true,
-			})
-			continue
+			Version  string
+			Name     string
+			Releases []BoshRelease
+			Tags     map[string]string
+		}{
+			Releases: cached.releases,
+			Tags:     cached.tags,
 		}
 
+		extractor := a.config.Bosh.Extractor(deployment.Name)
+		data.Name = extractor.Name(doc)
+		data.Version = extractor.Version(doc)
+
 		if data.Name == "" {
-			data.Name = deployment.Name()
+			data.Name = deployment.Name
 		}
 		if a.config.Bosh.IsExcluded(data.Name) {
 			log.Debugf("excluding deployment '%s'", data.Name)
 			continue
 		}
 
-		if data.Version == "" {
-			log.Errorf("unable to find manifest version for deployment '%s'", deployment.Name())
+		if data.Version == "" && a.config.Github.CanDetectVersion(data.Name) {
+			log.Debugf("missing manifest version for deployment '%s', to be detected", deployment.Name)
 			res = append(res, BoshDeploymentData{
-				Deployment: deployment.Name(),
-				HasError:   true,
+				Deployment:   deployment.Name,
+				ManifestName: data.Name,
+				HasError:     false,
+				BoshReleases: data.Releases,
+				Manifest:     manifest,
+				Tags:         data.Tags,
+				Teams:        a.getTeams(deployment),
+				Properties:   a.getProperties(doc),
 			})
+			a.fillInstanceGroups(deployment, &res[len(res)-1])
 			continue
 		}
 
-		if re.MatchString(data.Version) {
-			data.Version = re.ReplaceAllString(data.Version, "${1}")
+		// releases are kept, they can still be compared to generic releases
+		if data.Version == "" {
+			log.Errorf("unable to find manifest version for deployment '%s'", deployment.Name)
+			res = append(res, BoshDeploymentData{
+				Deployment:   deployment.Name,
+				ManifestName: data.Name,
+				HasError:     true,
+				BoshReleases: data.Releases,
+				Manifest:     manifest,
+			})
+			continue
 		}
 
 		res = append(res, BoshDeploymentData{
-			Deployment:   deployment.Name(),
+			Deployment:   deployment.Name,
 			ManifestName: data.Name,
 			Ref:          data.Version,
 			HasError:     false,
 			BoshReleases: data.Releases,
+			Manifest:     manifest,
+			Detection:    DetectionManifest,
+			Confidence:   1,
+			Tags:         data.Tags,
+			Teams:        a.getTeams(deployment),
+			Properties:   a.getProperties(doc),
 		})
+		a.fillInstanceGroups(deployment, &res[len(res)-1])
+	}
+	return res, nil
+}
+
+// GetDirector - Fetches director information, version is stripped from its build number
+func (a *Manager) GetDirector() (*DirectorData, error) {
+	log.WithField("name", "director").Debugf("processing director info")
+	if a.snapshot != nil {
+		if a.snapshot.Director == nil {
+			return nil, fmt.Errorf("no director info in snapshot")
+		}
+		return a.snapshot.Director, nil
+	}
+	info, err := a.director.Info()
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to fetch director info")
+	}
+	version := info.Version
+	if fields := strings.Fields(version); len(fields) != 0 {
+		version = fields[0]
+	}
+	return &DirectorData{
+		Name:            info.Name,
+		UUID:            info.UUID,
+		Version:         version,
+		CPI:             info.CPI,
+		StemcellOS:      info.StemcellOS,
+		StemcellVersion: info.StemcellVersion,
+	}, nil
+}
+
+// GetConfigs - Fetches releases of latest runtime and cloud configs of the director
+func (a *Manager) GetConfigs() ([]ConfigData, error) {
+	entry := log.WithField("name", "configs")
+	entry.Debugf("processing director configs")
+	if a.snapshot != nil {
+		return a.snapshot.Configs, nil
+	}
+
+	res := []ConfigData{}
+	for _, kind := range []string{"runtime", "cloud"} {
+		configs, err := a.director.ListConfigs(1, director.ConfigsFilter{Type: kind})
+		if err != nil {
+			return res, errors.Wrapf(err, "unable to fetch %s configs", kind)
+		}
+		for _, c := range configs {
+			entry.Debugf("processing %s config '%s'", kind, c.Name)
+			data := BoshManifest{}
+			if err := yaml.Unmarshal([]byte(c.Content), &data); err != nil {
+				log.Errorf("unable to parse %s config '%s': %+v", kind, c.Name, err)
+				res = append(res, ConfigData{Name: c.Name, Type: kind, HasError: true})
+				continue
+			}
+			res = append(res, ConfigData{
+				Name:         c.Name,
+				Type:         kind,
+				BoshReleases: data.Releases,
+			})
+		}
 	}
 	return res, nil
 }
 
+// fillInstanceGroups - Adds running state of instance groups, only in deep mode
+func (a *Manager) fillInstanceGroups(deployment *boshDeployment, target *BoshDeploymentData) {
+	if !a.config.Bosh.Deep {
+		return
+	}
+	groups, drifts, err := a.getInstanceGroups(deployment, target.Manifest)
+	if err != nil {
+		log.Warnf("unable to analyze instances of deployment '%s': %s", deployment.Name, err)
+		return
+	}
+	target.InstanceGroups = groups
+	target.Drifts = drifts
+}
+
+// getTeams - Gives director teams of given deployment, only when exposed as label
+func (a *Manager) getTeams(deployment *boshDeployment) []string {
+	if !a.config.Bosh.Labels.Teams || deployment.Teams == nil {
+		return []string{}
+	}
+	return deployment.Teams
+}
+
+// getProperties - Extracts manifest properties exposed as label
+func (a *Manager) getProperties(manifest interface{}) map[string]string {
+	res := map[string]string{}
+	for name, path := range a.config.Bosh.Labels.Properties {
+		res[name] = findString(manifest, path)
+	}
+	return res
+}
+
 // GetGenericReleases -
 func (a *Manager) GetGenericReleases() []GenericReleaseData {
+	return a.GetGenericReleasesFiltered(Filter{})
+}
+
+// GetGenericReleasesFiltered - Fetches generic releases selected by filter
+func (a *Manager) GetGenericReleasesFiltered(filter Filter) []GenericReleaseData {
 	results := []GenericReleaseData{}
+	if a.snapshot != nil {
+		for _, g := range a.snapshot.GenericReleases {
+			if filter.matchGeneric(g.Name, g.GenericReleaseConfig) {
+				results = append(results, g)
+			}
+		}
+		return results
+	}
 	for name, item := range a.config.Github.GenericReleases {
+		if !filter.matchGeneric(name, *item) {
+			continue
+		}
 		entry := log.WithFields(log.Fields{
 			"name":  name,
 			"repo":  item.Repo,
@@ -134,7 +338,7 @@ func (a *Manager) GetGenericReleases() []GenericReleaseData {
 		target := &results[len(results)-1]
 
 		entry.Debugf("fetching release list")
-		refs, err := a.getRefs(*item)
+		refs, err := a.getRefs(*item, nil)
 		if err != nil {
 			entry.Errorf("skiping generic release: %+v", err)
 			target.HasError = true
@@ -150,15 +354,32 @@ func (a *Manager) GetGenericReleases() []GenericReleaseData {
 
 		target.Versions = a.createVersions(refs, *lastRef, *item)
 		target.LatestVersion = NewVersion(lastRef.Ref, item.Format.Format(lastRef.Ref), lastRef.Time)
+		target.LatestVersion.Notes = lastRef.Notes
 	}
 	return results
 }
 
 // GetManifestReleases -
 func (a *Manager) GetManifestReleases() []ManifestReleaseData {
+	return a.GetManifestReleasesFiltered(Filter{})
+}
+
+// GetManifestReleasesFiltered - Fetches manifest releases selected by filter
+func (a *Manager) GetManifestReleasesFiltered(filter Filter) []ManifestReleaseData {
 	results := []ManifestReleaseData{}
+	if a.snapshot != nil {
+		for _, m := range a.snapshot.ManifestReleases {
+			if filter.matchManifest(m.Name, m.GenericReleaseConfig) {
+				results = append(results, m)
+			}
+		}
+		return results
+	}
 
 	for name, item := range a.config.Github.ManifestReleases {
+		if !filter.matchManifest(name, item.GenericReleaseConfig) {
+			continue
+		}
 		results = append(results, NewManifestReleaseData(*item, name))
 		target := &results[len(results)-1]
 
@@ -170,7 +391,7 @@ func (a *Manager) GetManifestReleases() []ManifestReleaseData {
 		entry.Debugf("processing bosh deployment")
 
 		entry.Debugf("fetching release list")
-		refs, err := a.getRefs(item.GenericReleaseConfig)
+		refs, err := a.getRefs(item.GenericReleaseConfig, item.breakingRegexps)
 		if err != nil {
 			entry.Errorf("skiping manifest release: %+v", err)
 			target.HasError = true
@@ -185,6 +406,7 @@ func (a *Manager) GetManifestReleases() []ManifestReleaseData {
 		}
 		target.Versions = a.createVersions(refs, *lastRef, item.GenericReleaseConfig)
 		target.LatestVersion = NewVersion(lastRef.Ref, item.Format.Format(lastRef.Ref), lastRef.Time)
+		target.LatestVersion.Notes = lastRef.Notes
 
 		entry = log.WithFields(log.Fields{
 			"deployment": name,
@@ -197,29 +419,142 @@ func (a *Manager) GetManifestReleases() []ManifestReleaseData {
 			continue
 		}
 
-		entry.Debugf("downloading manifest")
-		content, err := a.getContent(lastRef.Ref, *item, item.Manifest)
+		releases, err := a.renderBoshReleases(*target, nil)
 		if err != nil {
 			entry.Warnf("skiping manifest release: %+v", err)
 			continue
 		}
+		target.BoshReleases = releases
+	}
+	a.pruneRenders(results)
+	return results
+}
 
-		final, err := a.RenderManifest(content, *target)
+// GetDeploymentBoshReleases - Gives bosh releases recommended by latest version of given
+// manifest release for given running deployment
+//
+// Rendering uses ops-files and vars-files overridden for the deployment, if any, otherwise
+// ops-files inferred from the deployment when infer_ops is enabled. Variables are recovered
+// from the deployment when deployment_vars is enabled.
+func (a *Manager) GetDeploymentBoshReleases(deployment BoshDeploymentData, item ManifestReleaseData) ([]BoshRelease, error) {
+	if len(item.Manifest) == 0 {
+		return item.BoshReleases, nil
+	}
+	item.Ops, item.Vars = item.OpsFor(deployment.Deployment)
+	if item.InferOps && !item.HasOverride(deployment.Deployment) {
+		analysis, err := a.InferOps(deployment, item)
 		if err != nil {
-			entry.Warnf("skiping manifest release: %+v", err)
-			continue
+			return nil, errors.Wrapf(err, "unable to infer ops-files of deployment '%s'", deployment.Deployment)
 		}
+		item.Ops, err = a.availableOps(item, analysis.Ops)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to list ops-files of manifest release '%s'", item.Name)
+		}
+	}
+	if !item.DeploymentVars {
+		return a.renderBoshReleases(item, nil)
+	}
+	return a.renderBoshReleases(item, &deployment)
+}
 
-		entry.Debugf("extracting bosh-release versions")
-		var manifest BoshManifest
-		err = yaml.Unmarshal(final, &manifest)
+// renderBoshReleases - Renders latest version of given manifest release and extracts its bosh releases
+//
+// Results are cached by version, manifest, ops-files and vars-files. Manifests rendered with
+// variables of a deployment are never cached.
+func (a *Manager) renderBoshReleases(item ManifestReleaseData, deployment *BoshDeploymentData) ([]BoshRelease, error) {
+	entry := log.WithFields(log.Fields{
+		"name":    item.Name,
+		"repo":    item.Repo,
+		"owner":   item.Owner,
+		"version": item.LatestVersion.Version,
+	})
+
+	key := fmt.Sprintf("%s|%s|%s|%s",
+		repoRefKey(item.GenericReleaseConfig, item.LatestVersion.GitRef), item.Manifest,
+		strings.Join(item.Ops, ","), strings.Join(item.Vars, ","))
+	if deployment == nil {
+		a.mutex.Lock()
+		releases, found := a.renderCache[key]
+		a.mutex.Unlock()
+		if found {
+			entry.Debugf("using cached bosh-release versions")
+			return releases, nil
+		}
+	}
+
+	entry.Debugf("downloading manifest")
+	content, err := a.getContent(item.LatestVersion.GitRef, item.ManifestReleaseConfig, item.Manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := boshtpl.StaticVariables{}
+	if deployment != nil {
+		vars, err = a.getDeploymentVariables(*deployment, item, content)
 		if err != nil {
-			entry.Warnf("unable to parse manifest '%s': %+v", item.Manifest, err)
-			continue
+			return nil, err
 		}
-		target.BoshReleases = manifest.Releases
+		entry.Debugf("recovered %d variables from deployment '%s'", len(vars), deployment.Deployment)
 	}
-	return results
+
+	final, err := a.RenderManifestWithVars(content, item, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.Debugf("extracting bosh-release versions")
+	var manifest BoshManifest
+	if err = yaml.Unmarshal(final, &manifest); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse manifest '%s'", item.Manifest)
+	}
+
+	if deployment == nil {
+		a.mutex.Lock()
+		a.renderCache[key] = manifest.Releases
+		a.mutex.Unlock()
+	}
+	return manifest.Releases, nil
+}
+
+// getDeploymentVariables - Recovers values of variables used by given canonical manifest
+// from running deployment manifest
+//
+// Ops-files are applied beforehand, variables they introduce must be recovered as well.
+func (a *Manager) getDeploymentVariables(deployment BoshDeploymentData, item ManifestReleaseData, content []byte) (boshtpl.StaticVariables, error) {
+	excludes := map[string]bool{}
+	variables, err := a.director.FindDeployment(deployment.Deployment).Variables()
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to fetch variables of deployment '%s'", deployment.Deployment)
+	}
+	for _, v := range variables {
+		excludes[v.Name] = true
+		excludes[path.Base(v.Name)] = true
+	}
+
+	ops, err := a.getOpsFiles(item)
+	if err != nil {
+		return nil, err
+	}
+	expanded, err := boshtpl.NewTemplate(content).Evaluate(boshtpl.StaticVariables{}, ops, boshtpl.EvaluateOpts{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to apply ops-files to canonical manifest")
+	}
+
+	var tpl, actual interface{}
+	if err = yaml.Unmarshal(expanded, &tpl); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse canonical manifest")
+	}
+	if err = yaml.Unmarshal([]byte(deployment.Manifest), &actual); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse manifest of deployment '%s'", deployment.Deployment)
+	}
+	// deployed release and stemcell versions must not leak in recommended ones
+	if m, ok := tpl.(map[interface{}]interface{}); ok {
+		delete(m, "releases")
+		delete(m, "stemcells")
+	}
+	vars := boshtpl.StaticVariables{}
+	extractVariables(tpl, actual, excludes, vars)
+	return vars, nil
 }
 
 func (a *Manager) listReleases(item GenericReleaseConfig) ([]*github.RepositoryRelease, error) {
@@ -232,7 +567,7 @@ func (a *Manager) listReleases(item GenericReleaseConfig) ([]*github.RepositoryR
 	for {
 		data, resp, err := a.client.Repositories.ListReleases(a.ctx, item.Owner, item.Repo, &opts)
 		if err != nil {
-			return res, err
+			return res, checkRateLimit(err)
 		}
 		res = append(res, data...)
 		if resp.NextPage == 0 {
@@ -244,7 +579,9 @@ func (a *Manager) listReleases(item GenericReleaseConfig) ([]*github.RepositoryR
 
 //  1. For some reason, we get empty date when reading tag object
 //     We fetch information for corresponding sha to get tag date
-func (a *Manager) getRefs(item GenericReleaseConfig) ([]GithubRef, error) {
+//  2. Skipped in unauthenticated mode, one request per tag would quickly
+//     consume the quota. Tags are then ordered by semver only.
+func (a *Manager) getRefs(item GenericReleaseConfig, breaking []*regexp.Regexp) ([]GithubRef, error) {
 	res := []GithubRef{}
 	release := item.HasType("release")
 	preRelease := item.HasType("pre_release")
@@ -261,6 +598,7 @@ func (a *Manager) getRefs(item GenericReleaseConfig) ([]GithubRef, error) {
 				res = append(res, GithubRef{
 					r.GetTagName(),
 					r.GetCreatedAt().Unix(),
+					NewReleaseNotes(r.GetName(), r.GetHTMLURL(), r.GetBody(), breaking),
 				})
 			}
 		}
@@ -269,15 +607,21 @@ func (a *Manager) getRefs(item GenericReleaseConfig) ([]GithubRef, error) {
 	if item.HasType("tag") {
 		tags, _, err := a.client.Repositories.ListTags(a.ctx, item.Owner, item.Repo, nil)
 		if err != nil {
-			return res, errors.Wrapf(err, "unable to fetch tags from %s/%s", item.Owner, item.Repo)
+			return res, errors.Wrapf(checkRateLimit(err), "unable to fetch tags from %s/%s", item.Owner, item.Repo)
 		}
 		for _, t := range tags {
+			// 2.
+			if a.config.Github.IsAnonymous() {
+				res = append(res, GithubRef{t.GetName(), 0, nil})
+				continue
+			}
 			// 1.
 			sha1 := t.GetCommit().GetSHA()
 			commit, _, _ := a.client.Repositories.GetCommit(a.ctx, item.Owner, item.Repo, sha1)
 			res = append(res, GithubRef{
 				t.GetName(),
 				commit.GetCommit().GetCommitter().GetDate().Unix(),
+				nil,
 			})
 		}
 	}
@@ -304,11 +648,20 @@ func (a *Manager) getLastRef(refs []GithubRef) (*GithubRef, error) {
 	return &refs[0], nil
 }
 
+// getContent - Downloads file at given ref, contents are cached by repository, ref and path
 func (a *Manager) getContent(ref string, item ManifestReleaseConfig, path string) ([]byte, error) {
+	key := fmt.Sprintf("%s|%s", repoRefKey(item.GenericReleaseConfig, ref), path)
+	a.mutex.Lock()
+	cached, found := a.contentCache[key]
+	a.mutex.Unlock()
+	if found {
+		return cached, nil
+	}
+
 	opts := github.RepositoryContentGetOptions{Ref: ref}
 	stream, err := a.client.Repositories.DownloadContents(a.ctx, item.Owner, item.Repo, path, &opts)
 	if err != nil {
-		return []byte{}, errors.Wrapf(err, "could not download file '%s'", path)
+		return []byte{}, errors.Wrapf(checkRateLimit(err), "could not download file '%s'", path)
 	}
 
 	defer utils.CloseAndLogError(stream)
@@ -317,6 +670,70 @@ func (a *Manager) getContent(ref string, item ManifestReleaseConfig, path string
 		return []byte{}, errors.Wrapf(err, "could read remote stream")
 	}
 
+	a.mutex.Lock()
+	a.contentCache[key] = content
+	a.mutex.Unlock()
+	return content, nil
+}
+
+func (a *Manager) getOpsFile(ref string, item ManifestReleaseConfig, path string) (patch.Ops, error) {
+	val, err := a.getContent(ref, item, path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to fetch ops-file '%s'", path)
+	}
+	return parseOpsFile(val, path)
+}
+
+// getOpsFiles - Gives ops of all ops-files of given manifest release at its latest version
+func (a *Manager) getOpsFiles(item ManifestReleaseData) (patch.Ops, error) {
+	res := patch.Ops{}
+	for _, opPath := range item.Ops {
+		ops, err := a.getOpsFile(item.LatestVersion.GitRef, item.ManifestReleaseConfig, opPath)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, ops)
+	}
+	return res, nil
+}
+
+func parseOpsFile(val []byte, path string) (patch.Ops, error) {
+	var opDef []patch.OpDefinition
+	if err := yaml.Unmarshal(val, &opDef); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse ops-file '%s'", path)
+	}
+	ops, err := patch.NewOpsFromDefinitions(opDef)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to create ops from file '%s'", path)
+	}
+	return ops, nil
+}
+
+func (a *Manager) getVarsFile(ref string, item ManifestReleaseConfig, path string) (boshtpl.StaticVariables, error) {
+	val, err := a.getContent(ref, item, path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to fetch var-file '%s'", path)
+	}
+	vars := boshtpl.StaticVariables{}
+	if err = yaml.Unmarshal(val, &vars); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse var-file '%s'", path)
+	}
+	return vars, nil
+}
+
+func (a *Manager) download(url string) ([]byte, error) {
+	resp, err := a.http.Get(url)
+	if err != nil {
+		return []byte{}, errors.Wrapf(err, "could not download '%s'", url)
+	}
+	defer utils.CloseAndLogError(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return []byte{}, fmt.Errorf("could not download '%s': %s", url, resp.Status)
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return []byte{}, errors.Wrapf(err, "could read remote stream")
+	}
 	return content, nil
 }
 
@@ -324,6 +741,7 @@ func (a *Manager) createVersions(refs []GithubRef, last GithubRef, item GenericR
 	versions := []Version{}
 	for idx, ref := range refs {
 		v := NewVersion(ref.Ref, item.Format.Format(ref.Ref), ref.Time)
+		v.Notes = ref.Notes
 		if idx != 0 {
 			v.ExpiredSince = refs[idx-1].Time
 		}
@@ -334,6 +752,11 @@ func (a *Manager) createVersions(refs []GithubRef, last GithubRef, item GenericR
 
 // RenderManifest -
 func (a *Manager) RenderManifest(manifest []byte, item ManifestReleaseData) ([]byte, error) {
+	return a.RenderManifestWithVars(manifest, item, boshtpl.StaticVariables{})
+}
+
+// RenderManifestWithVars - Renders manifest, given variables complete values from vars-files
+func (a *Manager) RenderManifestWithVars(manifest []byte, item ManifestReleaseData, extraVars boshtpl.Variables) ([]byte, error) {
 	entry := log.WithFields(log.Fields{
 		"name":  item.Name,
 		"repo":  item.Repo,
@@ -346,20 +769,12 @@ func (a *Manager) RenderManifest(manifest []byte, item ManifestReleaseData) ([]b
 	var opList patch.Ops
 	var opListFinal patch.Ops
 	for _, opPath := range item.Ops {
-		val, err := a.getContent(item.LatestVersion.GitRef, item.ManifestReleaseConfig, opPath)
+		ops, err := a.getOpsFile(item.LatestVersion.GitRef, item.ManifestReleaseConfig, opPath)
 		if err != nil {
-			return []byte{}, errors.Wrapf(err, "unable to fetch ops-file '%s'", opPath)
-		}
-		var opDef []patch.OpDefinition
-		if err = yaml.Unmarshal(val, &opDef); err != nil {
-			return []byte{}, errors.Wrapf(err, "unable to parse ops-file '%s'", opPath)
-		}
-		ops, err := patch.NewOpsFromDefinitions(opDef)
-		if err != nil {
-			return []byte{}, errors.Wrapf(err, "unable to create ops from file '%s'", opPath)
+			return []byte{}, err
 		}
 		opList = append(opList, ops)
-		_, err = tpl.Evaluate(boshtpl.MultiVars{}, opList, boshtpl.EvaluateOpts{})
+		_, err = tpl.Evaluate(extraVars, opList, boshtpl.EvaluateOpts{})
 		if err != nil {
 			return []byte{}, errors.Wrapf(err, "unable to evaluate ops file '%s'", opPath)
 		}
@@ -368,16 +783,13 @@ func (a *Manager) RenderManifest(manifest []byte, item ManifestReleaseData) ([]b
 
 	varList := []boshtpl.Variables{}
 	for _, varPath := range item.Vars {
-		val, err := a.getContent(item.LatestVersion.GitRef, item.ManifestReleaseConfig, varPath)
+		vars, err := a.getVarsFile(item.LatestVersion.GitRef, item.ManifestReleaseConfig, varPath)
 		if err != nil {
-			return []byte{}, errors.Wrapf(err, "unable to fetch var-file '%s'", varPath)
-		}
-		vars := boshtpl.StaticVariables{}
-		if err = yaml.Unmarshal(val, &vars); err != nil {
-			return []byte{}, errors.Wrapf(err, "unable to parse var-file '%s'", varPath)
+			return []byte{}, err
 		}
 		varList = append(varList, vars)
 	}
+	varList = append(varList, extraVars)
 
 	res, err := tpl.Evaluate(boshtpl.NewMultiVars(varList), opListFinal, boshtpl.EvaluateOpts{})
 	if err != nil {
@@ -24,16 +24,18 @@ func (s *Formatter) DoesMatch(ref string) bool {
 
 // GithubRef -
 type GithubRef struct {
-	Ref  string
-	Time int64
+	Ref   string
+	Time  int64
+	Notes *ReleaseNotes
 }
 
 // Version -
 type Version struct {
-	GitRef       string `yaml:"gitref"`
-	Version      string `yaml:"version"`
-	Time         int64  `yaml:"time"`
-	ExpiredSince int64  `yaml:"expired_since"`
+	GitRef       string        `json:"gitref" yaml:"gitref"`
+	Version      string        `json:"version" yaml:"version"`
+	Time         int64         `json:"time" yaml:"time"`
+	ExpiredSince int64         `json:"expired_since" yaml:"expired_since"`
+	Notes        *ReleaseNotes `json:"notes,omitempty" yaml:"notes,omitempty"`
 }
 
 func NewVersion(gitref string, version string, timestamp int64) Version {
@@ -55,11 +57,35 @@ func (r Version) GetStatus(latest Version) string {
 
 // BoshDeploymentData -
 type BoshDeploymentData struct {
-	Deployment   string        `yaml:"deployment"`
-	ManifestName string        `yaml:"manifest"`
-	Ref          string        `yaml:"current"`
-	HasError     bool          `yaml:"has_error"`
-	BoshReleases []BoshRelease `yaml:"bosh_releases"`
+	Deployment   string            `yaml:"deployment"`
+	ManifestName string            `yaml:"manifest"`
+	Ref          string            `yaml:"current"`
+	HasError     bool              `yaml:"has_error"`
+	BoshReleases []BoshRelease     `yaml:"bosh_releases"`
+	Manifest     string            `yaml:"-"`
+	Detection    string            `yaml:"detection"`
+	Confidence   float64           `yaml:"confidence"`
+	Tags         map[string]string `yaml:"tags"`
+	Teams        []string          `yaml:"teams"`
+	Properties   map[string]string `yaml:"properties"`
+	// only filled once resolved
+	Recommended []BoshRelease `yaml:"recommended_bosh_releases,omitempty"`
+	// only filled in deep mode
+	InstanceGroups []InstanceGroupData `yaml:"instance_groups,omitempty"`
+	Drifts         []string            `yaml:"drifts,omitempty"`
+}
+
+// InstanceGroupData - Running state of an instance group, releases being those declared
+// by the deployed manifest
+type InstanceGroupData struct {
+	Name             string         `yaml:"name"`
+	Instances        int            `yaml:"instances"`
+	Failing          int            `yaml:"failing"`
+	ExpectedStemcell string         `yaml:"expected_stemcell"`
+	Stemcells        map[string]int `yaml:"stemcells"`
+	Releases         []BoshRelease  `yaml:"bosh_releases"`
+	HasDrift         bool           `yaml:"has_drift"`
+	expectedName     string
 }
 
 // GenericReleaseData -
@@ -84,6 +110,7 @@ type BoshRelease struct {
 	Name    string `yaml:"name"`
 	URL     string `yaml:"url"`
 	Version string `yaml:"version"`
+	SHA1    string `yaml:"sha1,omitempty"`
 }
 
 // ManifestReleaseData -
@@ -106,5 +133,60 @@ func NewManifestReleaseData(config ManifestReleaseConfig, name string) ManifestR
 
 // BoshManifest -
 type BoshManifest struct {
-	Releases []BoshRelease `yaml:"releases"`
+	Releases  []BoshRelease  `yaml:"releases"`
+	Stemcells []BoshStemcell `yaml:"stemcells"`
+}
+
+// BoshStemcell - Stemcell declared by a manifest
+type BoshStemcell struct {
+	Alias   string `yaml:"alias"`
+	OS      string `yaml:"os"`
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+}
+
+// DirectorData -
+type DirectorData struct {
+	Name            string `yaml:"name"`
+	UUID            string `yaml:"uuid"`
+	Version         string `yaml:"version"`
+	CPI             string `yaml:"cpi"`
+	StemcellOS      string `yaml:"stemcell_os"`
+	StemcellVersion string `yaml:"stemcell_version"`
+}
+
+// AssetData - Release or stemcell uploaded to the director
+type AssetData struct {
+	Type        string   `yaml:"type" json:"type"`
+	Name        string   `yaml:"name" json:"name"`
+	OS          string   `yaml:"os,omitempty" json:"os,omitempty"`
+	Version     string   `yaml:"version" json:"version"`
+	Deployments []string `yaml:"deployments" json:"deployments"`
+	UnusedSince int64    `yaml:"unused_since" json:"unused_since"`
+	CleanUp     bool     `yaml:"clean_up" json:"clean_up"`
+	CleanUpAll  bool     `yaml:"clean_up_all" json:"clean_up_all"`
+}
+
+// IsUsed - Tells if asset is used by at least one deployment
+func (a AssetData) IsUsed() bool {
+	return len(a.Deployments) != 0
+}
+
+// ConfigData - Releases of a director runtime or cloud config
+type ConfigData struct {
+	Name         string        `yaml:"name"`
+	Type         string        `yaml:"type"`
+	HasError     bool          `yaml:"has_error"`
+	BoshReleases []BoshRelease `yaml:"bosh_releases"`
+}
+
+// OpsAnalysis - Upstream ops-files explaining bosh releases of a deployment
+type OpsAnalysis struct {
+	Deployment   string   `yaml:"deployment" json:"deployment"`
+	ManifestName string   `yaml:"manifest" json:"manifest"`
+	Version      string   `yaml:"version" json:"version"`
+	Ops          []string `yaml:"ops" json:"ops"`
+	Matched      []string `yaml:"matched" json:"matched"`
+	Missing      []string `yaml:"missing" json:"missing"`
+	Extra        []string `yaml:"extra" json:"extra"`
 }
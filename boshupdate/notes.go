@@ -0,0 +1,118 @@
+package boshupdate
+
+import (
+	"regexp"
+	"sort"
+)
+
+const (
+	// KeywordBreaking - Release notes mention breaking changes
+	KeywordBreaking = "breaking"
+	// KeywordCVE - Release notes reference a CVE
+	KeywordCVE = "cve"
+	// KeywordSecurity - Release notes mention security
+	KeywordSecurity = "security"
+)
+
+var (
+	keywordRegexes = map[string]*regexp.Regexp{
+		KeywordBreaking: regexp.MustCompile(`(?i)\bbreaking\b`),
+		KeywordCVE:      regexp.MustCompile(`(?i)\bCVE-\d{4}-\d+`),
+		KeywordSecurity: regexp.MustCompile(`(?i)\bsecurity\b`),
+	}
+)
+
+// ReleaseNotes - Notes of a GitHub release with the keywords they mention
+//
+// Only GitHub releases have notes, tags have none.
+type ReleaseNotes struct {
+	Name     string   `json:"name" yaml:"name"`
+	URL      string   `json:"url" yaml:"url"`
+	Body     string   `json:"body,omitempty" yaml:"body,omitempty"`
+	Keywords []string `json:"keywords" yaml:"keywords"`
+}
+
+// NewReleaseNotes - Creates release notes from keywords found in body
+//
+// Given breaking patterns, if any, replace the default one of breaking keyword.
+func NewReleaseNotes(name string, url string, body string, breaking []*regexp.Regexp) *ReleaseNotes {
+	res := &ReleaseNotes{
+		Name:     name,
+		URL:      url,
+		Body:     body,
+		Keywords: []string{},
+	}
+	for k, re := range keywordRegexes {
+		if k == KeywordBreaking && len(breaking) != 0 {
+			continue
+		}
+		if re.MatchString(body) {
+			res.Keywords = append(res.Keywords, k)
+		}
+	}
+	for _, re := range breaking {
+		if re.MatchString(body) {
+			res.Keywords = append(res.Keywords, KeywordBreaking)
+			break
+		}
+	}
+	sort.Strings(res.Keywords)
+	return res
+}
+
+// HasKeyword - Tells if release notes mention given keyword
+func (n *ReleaseNotes) HasKeyword(keyword string) bool {
+	if n == nil {
+		return false
+	}
+	for _, k := range n.Keywords {
+		if k == keyword {
+			return true
+		}
+	}
+	return false
+}
+
+// HasSecurityFix - Tells if release notes mention a CVE or security
+func (n *ReleaseNotes) HasSecurityFix() bool {
+	return n.HasKeyword(KeywordCVE) || n.HasKeyword(KeywordSecurity)
+}
+
+// DeploymentChangelog - Release notes of versions between deployed and latest version
+type DeploymentChangelog struct {
+	Deployment     string    `json:"deployment" yaml:"deployment"`
+	ManifestName   string    `json:"manifest_name" yaml:"manifest_name"`
+	From           string    `json:"from" yaml:"from"`
+	To             string    `json:"to" yaml:"to"`
+	HasSecurityFix bool      `json:"has_security_fix" yaml:"has_security_fix"`
+	Versions       []Version `json:"versions" yaml:"versions"`
+}
+
+// Changelog - Aggregates release notes of versions newer than the deployed one, oldest first
+func Changelog(deployment BoshDeploymentData, item ManifestReleaseData) DeploymentChangelog {
+	res := DeploymentChangelog{
+		Deployment:   deployment.Deployment,
+		ManifestName: item.Name,
+		From:         deployment.Ref,
+		To:           item.LatestVersion.Version,
+		Versions:     []Version{},
+	}
+	// versions are sorted newest first, nothing is newer than an unknown version
+	current := 0
+	for idx, v := range item.Versions {
+		if v.Version == deployment.Ref {
+			current = idx
+			break
+		}
+	}
+	for idx := current - 1; idx >= 0; idx-- {
+		v := item.Versions[idx]
+		res.HasSecurityFix = res.HasSecurityFix || v.Notes.HasSecurityFix()
+		res.Versions = append(res.Versions, v)
+	}
+	return res
+}
+
+// Local Variables:
+// ispell-local-dictionary: "american"
+// End:
@@ -0,0 +1,74 @@
+package boshupdate
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestNewReleaseNotes(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		breaking []*regexp.Regexp
+		expected []string
+	}{
+		{name: "no keyword", body: "bump dependencies", expected: []string{}},
+		{name: "default keywords", body: "Breaking: fixes CVE-2024-1234, security", expected: []string{KeywordBreaking, KeywordCVE, KeywordSecurity}},
+		{name: "whole words only", body: "nonbreaking change", expected: []string{}},
+		{name: "breaking patterns", body: "removed property", breaking: []*regexp.Regexp{regexp.MustCompile(`removed`)}, expected: []string{KeywordBreaking}},
+		{name: "patterns replace default", body: "breaking change", breaking: []*regexp.Regexp{regexp.MustCompile(`removed`)}, expected: []string{}},
+	}
+
+	for _, tt := range tests {
+		res := NewReleaseNotes("v1", "https://example.com", tt.body, tt.breaking)
+		if !reflect.DeepEqual(res.Keywords, tt.expected) {
+			t.Errorf("%s: expected keywords %v, got %v", tt.name, tt.expected, res.Keywords)
+		}
+		if res.Body != tt.body {
+			t.Errorf("%s: expected body to be kept, got '%s'", tt.name, res.Body)
+		}
+	}
+}
+
+func TestChangelog(t *testing.T) {
+	security := &ReleaseNotes{Keywords: []string{KeywordSecurity}}
+	item := ManifestReleaseData{
+		Name: "cf",
+		Versions: []Version{
+			{Version: "3.0.0"},
+			{Version: "2.0.0", Notes: security},
+			{Version: "1.0.0"},
+		},
+		LatestVersion: Version{Version: "3.0.0"},
+	}
+
+	tests := []struct {
+		name        string
+		ref         string
+		expected    []string
+		securityFix bool
+	}{
+		{name: "oldest version", ref: "1.0.0", expected: []string{"2.0.0", "3.0.0"}, securityFix: true},
+		{name: "previous version", ref: "2.0.0", expected: []string{"3.0.0"}, securityFix: false},
+		{name: "latest version", ref: "3.0.0", expected: []string{}, securityFix: false},
+		{name: "unknown version", ref: "0.1.0", expected: []string{}, securityFix: false},
+	}
+
+	for _, tt := range tests {
+		res := Changelog(BoshDeploymentData{Deployment: "cf", Ref: tt.ref}, item)
+		versions := []string{}
+		for _, v := range res.Versions {
+			versions = append(versions, v.Version)
+		}
+		if !reflect.DeepEqual(versions, tt.expected) {
+			t.Errorf("%s: expected versions %v, got %v", tt.name, tt.expected, versions)
+		}
+		if res.HasSecurityFix != tt.securityFix {
+			t.Errorf("%s: expected security fix %t, got %t", tt.name, tt.securityFix, res.HasSecurityFix)
+		}
+		if res.From != tt.ref || res.To != "3.0.0" {
+			t.Errorf("%s: unexpected bounds %s -> %s", tt.name, res.From, res.To)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package boshupdate
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// UpgradeHop - Upgrade step between two versions of a manifest release
+type UpgradeHop struct {
+	From     string        `json:"from" yaml:"from"`
+	To       string        `json:"to" yaml:"to"`
+	Required bool          `json:"required" yaml:"required"`
+	Breaking []string      `json:"breaking" yaml:"breaking"`
+	Changes  *ManifestDiff `json:"changes" yaml:"changes"`
+}
+
+// UpgradePlan - Ordered upgrade steps from deployed version to latest version
+type UpgradePlan struct {
+	Deployment   string       `json:"deployment" yaml:"deployment"`
+	ManifestName string       `json:"manifest_name" yaml:"manifest_name"`
+	From         string       `json:"from" yaml:"from"`
+	To           string       `json:"to" yaml:"to"`
+	Hops         []UpgradeHop `json:"hops" yaml:"hops"`
+}
+
+// breakingVersions - Gives versions whose release notes mention breaking changes
+func breakingVersions(item ManifestReleaseData, versions []Version) []string {
+	res := []string{}
+	for _, v := range versions {
+		if v.Notes == nil {
+			log.Debugf("no release notes for version '%s' of manifest release '%s'", v.Version, item.Name)
+			continue
+		}
+		if v.Notes.HasKeyword(KeywordBreaking) {
+			res = append(res, v.Version)
+		}
+	}
+	return res
+}
+
+// upgradeHops - Splits upgrade from version at index current up to the latest version
+//
+//  1. Versions are crossed in ascending order, each configured required stop newer
+//     than the deployed version ends a hop.
+//  2. Hops are flagged breaking when release notes of a crossed version match one of
+//     the configured breaking patterns.
+func upgradeHops(item ManifestReleaseData, current int) []UpgradeHop {
+	stops := map[string]bool{}
+	for _, s := range item.RequiredStops {
+		stops[s] = true
+	}
+
+	// versions are sorted newest first
+	res := []UpgradeHop{}
+	start := current
+	for idx := current - 1; idx >= 0; idx-- {
+		v := item.Versions[idx]
+		// 1.
+		if idx != 0 && !stops[v.Version] {
+			continue
+		}
+		// 2.
+		crossed := []Version{}
+		for i := start - 1; i >= idx; i-- {
+			crossed = append(crossed, item.Versions[i])
+		}
+		res = append(res, UpgradeHop{
+			From:     item.Versions[start].Version,
+			To:       v.Version,
+			Required: stops[v.Version],
+			Breaking: breakingVersions(item, crossed),
+		})
+		start = idx
+	}
+	return res
+}
+
+// PlanUpgrade - Computes upgrade path of deployment up to the latest manifest version
+//
+// Hops are split by upgradeHops, each one is rendered with ops-files and vars-files of
+// the deployment overrides, if any.
+func (a *Manager) PlanUpgrade(deployment BoshDeploymentData, item ManifestReleaseData) (*UpgradePlan, error) {
+	current := -1
+	for idx, v := range item.Versions {
+		if v.Version == deployment.Ref {
+			current = idx
+			break
+		}
+	}
+	if current == -1 {
+		return nil, fmt.Errorf("unable to find version '%s' of manifest release '%s'", deployment.Ref, item.Name)
+	}
+
+	item.Ops, item.Vars = item.OpsFor(deployment.Deployment)
+	res := &UpgradePlan{
+		Deployment:   deployment.Deployment,
+		ManifestName: item.Name,
+		From:         deployment.Ref,
+		To:           item.LatestVersion.Version,
+		Hops:         upgradeHops(item, current),
+	}
+	for idx, hop := range res.Hops {
+		changes, err := a.DiffManifest(item, hop.From, hop.To)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to compare version '%s' to '%s'", hop.From, hop.To)
+		}
+		res.Hops[idx].Changes = changes
+	}
+	return res, nil
+}
+
+// Local Variables:
+// ispell-local-dictionary: "american"
+// End:
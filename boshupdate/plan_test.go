@@ -0,0 +1,63 @@
+package boshupdate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUpgradeHops(t *testing.T) {
+	breaking := &ReleaseNotes{Keywords: []string{KeywordBreaking}}
+	item := ManifestReleaseData{
+		ManifestReleaseConfig: ManifestReleaseConfig{RequiredStops: []string{"2.0.0", "4.0.0"}},
+		Versions: []Version{
+			{Version: "5.0.0"},
+			{Version: "4.0.0"},
+			{Version: "3.0.0", Notes: breaking},
+			{Version: "2.0.0"},
+			{Version: "1.0.0"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		current  int
+		expected []UpgradeHop
+	}{
+		{
+			name:     "up to date",
+			current:  0,
+			expected: []UpgradeHop{},
+		},
+		{
+			name:    "stops newer than deployed version",
+			current: 4,
+			expected: []UpgradeHop{
+				{From: "1.0.0", To: "2.0.0", Required: true, Breaking: []string{}},
+				{From: "2.0.0", To: "4.0.0", Required: true, Breaking: []string{"3.0.0"}},
+				{From: "4.0.0", To: "5.0.0", Required: false, Breaking: []string{}},
+			},
+		},
+		{
+			name:    "deployed on a stop",
+			current: 1,
+			expected: []UpgradeHop{
+				{From: "4.0.0", To: "5.0.0", Required: false, Breaking: []string{}},
+			},
+		},
+		{
+			name:    "deployed between stops",
+			current: 2,
+			expected: []UpgradeHop{
+				{From: "3.0.0", To: "4.0.0", Required: true, Breaking: []string{}},
+				{From: "4.0.0", To: "5.0.0", Required: false, Breaking: []string{}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		res := upgradeHops(item, tt.current)
+		if !reflect.DeepEqual(res, tt.expected) {
+			t.Errorf("%s: unexpected hops:\n got: %+v\nwant: %+v", tt.name, res, tt.expected)
+		}
+	}
+}
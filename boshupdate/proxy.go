@@ -0,0 +1,153 @@
+package boshupdate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	stdlog "log"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/bosh-utils/httpclient"
+	sshproxy "github.com/cloudfoundry/socks5-proxy"
+	"github.com/pkg/errors"
+	"golang.org/x/net/proxy"
+)
+
+// JumpboxConfig - SSH jumpbox tunneling director and UAA connections
+type JumpboxConfig struct {
+	Host       string `yaml:"host"`
+	User       string `yaml:"user"`
+	PrivateKey string `yaml:"private_key"`
+}
+
+func (c *JumpboxConfig) validate(offline bool) error {
+	if len(c.Host) == 0 {
+		return fmt.Errorf("missing mandatory host")
+	}
+	if _, _, err := net.SplitHostPort(c.Host); err != nil {
+		c.Host = net.JoinHostPort(c.Host, "22")
+	}
+	if len(c.PrivateKey) == 0 {
+		return fmt.Errorf("missing mandatory private_key")
+	}
+	if offline {
+		return nil
+	}
+	val, err := os.ReadFile(c.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("unable to read file at path %s", c.PrivateKey)
+	}
+	c.PrivateKey = string(val)
+	return nil
+}
+
+// parseProxyURL - Converts bosh-cli 'ssh+socks5://user@host:port?private-key=path' proxy
+// urls to jumpbox configuration, other urls are kept as is
+func parseProxyURL(value string) (string, *JumpboxConfig, error) {
+	if !strings.HasPrefix(value, "ssh+") {
+		if _, err := url.Parse(value); err != nil {
+			return "", nil, fmt.Errorf("invalid proxy url '%s'", value)
+		}
+		return value, nil, nil
+	}
+
+	u, err := url.Parse(strings.TrimPrefix(value, "ssh+"))
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid proxy url '%s'", value)
+	}
+	return "", &JumpboxConfig{
+		Host:       u.Host,
+		User:       u.User.Username(),
+		PrivateKey: u.Query().Get("private-key"),
+	}, nil
+}
+
+// deadlineConn - Connection closed once given timeout elapsed
+type deadlineConn struct {
+	net.Conn
+	timer *time.Timer
+}
+
+// Close -
+func (c *deadlineConn) Close() error {
+	c.timer.Stop()
+	return c.Conn.Close()
+}
+
+// withDeadline - Closes connections opened by dialer after timeout
+//
+// bosh-cli requests can't be canceled but its director and UAA clients don't reuse
+// connections, bounding connection lifetime bounds each request. Connections tunneled
+// through SSH don't support deadlines, hence the timer.
+func withDeadline(dialer httpclient.DialContextFunc, timeout time.Duration) httpclient.DialContextFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dialer(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		return &deadlineConn{
+			Conn:  conn,
+			timer: time.AfterFunc(timeout, func() { conn.Close() }),
+		}, nil
+	}
+}
+
+// newProxyDialer - Creates dialer according to director proxy configuration
+//
+// SSH tunnel is opened on first connection and shared by director and UAA clients.
+func newProxyDialer(config BoshConfig) (httpclient.DialContextFunc, error) {
+	direct := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+
+	if config.Jumpbox != nil {
+		jumpbox := *config.Jumpbox
+		tunnel := sshproxy.NewSocks5Proxy(sshproxy.NewHostKey(), stdlog.New(io.Discard, "", stdlog.LstdFlags), time.Minute)
+		var (
+			mutex  sync.Mutex
+			dialer sshproxy.DialFunc
+		)
+		return func(_ context.Context, network, address string) (net.Conn, error) {
+			mutex.Lock()
+			if dialer == nil {
+				d, err := tunnel.Dialer(jumpbox.User, jumpbox.PrivateKey, jumpbox.Host)
+				if err != nil {
+					mutex.Unlock()
+					return nil, errors.Wrapf(err, "unable to open ssh tunnel through jumpbox '%s'", jumpbox.Host)
+				}
+				dialer = d
+			}
+			mutex.Unlock()
+			return dialer(network, address)
+		}, nil
+	}
+
+	if config.Proxy == "" {
+		return direct.DialContext, nil
+	}
+
+	u, err := url.Parse(config.Proxy)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid proxy url '%s'", config.Proxy)
+	}
+	dialer, err := proxy.FromURL(u, direct)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unsupported proxy url '%s'", config.Proxy)
+	}
+	if d, ok := dialer.(proxy.ContextDialer); ok {
+		return d.DialContext, nil
+	}
+	return func(_ context.Context, network, address string) (net.Conn, error) {
+		return dialer.Dial(network, address)
+	}, nil
+}
+
+// Local Variables:
+// ispell-local-dictionary: "american"
+// End:
@@ -0,0 +1,270 @@
+package boshupdate
+
+import (
+	"fmt"
+)
+
+const (
+	// NotFound - Latest version of releases missing from manifest and generic releases
+	NotFound = "not-found"
+)
+
+// ReleaseStatus - Current and latest versions of a bosh release
+type ReleaseStatus struct {
+	Name          string `json:"name" yaml:"name"`
+	Current       string `json:"current" yaml:"current"`
+	Latest        string `json:"latest" yaml:"latest"`
+	OutdatedSince int64  `json:"outdated_since" yaml:"outdated_since"`
+}
+
+// IsOutdated - Tells if a newer version of the bosh release is known
+func (s ReleaseStatus) IsOutdated() bool {
+	return s.Latest != NotFound && s.Latest != s.Current
+}
+
+// DeploymentStatus - Current and latest versions of a deployment and its bosh releases
+type DeploymentStatus struct {
+	Deployment    string             `json:"deployment" yaml:"deployment"`
+	ManifestName  string             `json:"manifest_name" yaml:"manifest_name"`
+	Current       string             `json:"current" yaml:"current"`
+	Latest        string             `json:"latest" yaml:"latest"`
+	OutdatedSince int64              `json:"outdated_since" yaml:"outdated_since"`
+	Detection     string             `json:"detection" yaml:"detection"`
+	Confidence    float64            `json:"confidence" yaml:"confidence"`
+	Releases      []ReleaseStatus    `json:"bosh_releases" yaml:"bosh_releases"`
+	Data          BoshDeploymentData `json:"-" yaml:"-"`
+}
+
+// FindVersion - Gives manifest release matching deployment and its version currently deployed
+func FindVersion(deployment BoshDeploymentData, manifests []ManifestReleaseData) (*ManifestReleaseData, *Version) {
+	for _, r := range manifests {
+		if !r.Match(deployment.ManifestName) {
+			continue
+		}
+		for _, v := range r.Versions {
+			if v.Version == deployment.Ref {
+				return &r, &v
+			}
+		}
+	}
+	return nil, nil
+}
+
+// findBoshRelease - Gives bosh release of given name in releases, if any
+func findBoshRelease(releases []BoshRelease, name string) *BoshRelease {
+	for _, br := range releases {
+		if br.Name == name {
+			return &br
+		}
+	}
+	return nil
+}
+
+// genericStatus - Gives status of bosh release tracked by a generic release
+//
+// Generic releases tell when each version became out of date, latest release time
+// is used when current version is unknown.
+func genericStatus(br BoshRelease, match func(GenericReleaseData) bool, generics []GenericReleaseData) (ReleaseStatus, bool) {
+	for _, g := range generics {
+		if g.HasError || !match(g) {
+			continue
+		}
+		res := ReleaseStatus{Name: br.Name, Current: br.Version, Latest: g.LatestVersion.Version}
+		res.OutdatedSince = g.LatestVersion.Time
+		for _, v := range g.Versions {
+			if v.Version == br.Version {
+				res.OutdatedSince = v.ExpiredSince
+				break
+			}
+		}
+		return res, true
+	}
+	return ReleaseStatus{}, false
+}
+
+// Reconcile - Joins deployments with canonical manifests and generic releases
+//
+//  1. Deployments in error are ignored.
+//  2. Bosh releases are compared to releases recommended for the deployment when rendered,
+//     to releases of the latest manifest version otherwise.
+//  3. Bosh releases missing from the manifest are compared to the generic release declaring
+//     the same bosh_release, if any.
+//  4. Manifest releases don't tell when a bosh release became out of date, the time since
+//     the deployment is out of date is used.
+func Reconcile(deployments []BoshDeploymentData, manifests []ManifestReleaseData, generics []GenericReleaseData) []DeploymentStatus {
+	res := []DeploymentStatus{}
+	for _, d := range deployments {
+		// 1.
+		if d.HasError {
+			continue
+		}
+
+		status := DeploymentStatus{
+			Deployment:   d.Deployment,
+			ManifestName: d.ManifestName,
+			Current:      d.Ref,
+			Latest:       NotFound,
+			Detection:    d.Detection,
+			Confidence:   d.Confidence,
+			Releases:     []ReleaseStatus{},
+			Data:         d,
+		}
+		manifest, version := FindVersion(d, manifests)
+		if manifest == nil || version == nil {
+			res = append(res, status)
+			continue
+		}
+
+		status.ManifestName = manifest.Name
+		status.Current = version.Version
+		status.Latest = manifest.LatestVersion.Version
+		status.OutdatedSince = version.ExpiredSince
+
+		// 2.
+		recommended := manifest.BoshReleases
+		if d.Recommended != nil {
+			recommended = d.Recommended
+		}
+		for _, br := range d.BoshReleases {
+			latestBr := findBoshRelease(recommended, br.Name)
+			if latestBr == nil {
+				// 3.
+				rs, found := genericStatus(br, func(g GenericReleaseData) bool {
+					return g.BoshRelease == br.Name
+				}, generics)
+				if !found {
+					rs = ReleaseStatus{Name: br.Name, Current: br.Version, Latest: NotFound}
+				}
+				status.Releases = append(status.Releases, rs)
+				continue
+			}
+			rs := ReleaseStatus{Name: br.Name, Current: br.Version, Latest: latestBr.Version}
+			// 4.
+			if br.Version != latestBr.Version {
+				rs.OutdatedSince = version.ExpiredSince
+			}
+			status.Releases = append(status.Releases, rs)
+		}
+		res = append(res, status)
+	}
+	return res
+}
+
+// ReconcileConfig - Gives status of bosh releases of a director runtime or cloud config
+//
+// Releases are first compared to manifest releases matching config name, then to generic
+// releases tracking the same bosh release. Manifest releases don't tell when a bosh release
+// became out of date, the time of latest manifest version is used.
+func ReconcileConfig(config ConfigData, manifests []ManifestReleaseData, generics []GenericReleaseData) []ReleaseStatus {
+	res := []ReleaseStatus{}
+	for _, br := range config.BoshReleases {
+		rs, found := manifestStatus(br, func(m ManifestReleaseData) bool {
+			return m.Match(config.Name)
+		}, manifests)
+		if !found {
+			rs, found = genericStatus(br, func(g GenericReleaseData) bool {
+				return g.BoshRelease == br.Name
+			}, generics)
+		}
+		if !found {
+			rs = ReleaseStatus{Name: br.Name, Current: br.Version, Latest: NotFound}
+		}
+		res = append(res, rs)
+	}
+	return res
+}
+
+// ReconcileDirector - Gives status of director version compared to the bosh release given
+// by source, either a manifest release or a generic release
+func ReconcileDirector(director DirectorData, source string, manifests []ManifestReleaseData, generics []GenericReleaseData) ReleaseStatus {
+	current := BoshRelease{Name: "bosh", Version: director.Version}
+	rs, found := manifestStatus(current, func(m ManifestReleaseData) bool {
+		return m.Name == source
+	}, manifests)
+	if !found {
+		rs, found = genericStatus(current, func(g GenericReleaseData) bool {
+			return g.Name == source
+		}, generics)
+	}
+	if !found {
+		rs = ReleaseStatus{Name: current.Name, Current: current.Version, Latest: NotFound}
+	}
+	return rs
+}
+
+// manifestStatus - Gives status of bosh release recommended by the latest version of a manifest release
+func manifestStatus(br BoshRelease, match func(ManifestReleaseData) bool, manifests []ManifestReleaseData) (ReleaseStatus, bool) {
+	for _, m := range manifests {
+		if m.HasError || !match(m) {
+			continue
+		}
+		latestBr := findBoshRelease(m.BoshReleases, br.Name)
+		if latestBr == nil {
+			continue
+		}
+		res := ReleaseStatus{Name: br.Name, Current: br.Version, Latest: latestBr.Version}
+		if latestBr.Version != br.Version {
+			res.OutdatedSince = m.LatestVersion.Time
+		}
+		return res, true
+	}
+	return ReleaseStatus{}, false
+}
+
+// DeploymentError - Failure to prepare given deployment for reconciliation
+type DeploymentError struct {
+	Deployment string
+	Message    string
+}
+
+// Error -
+func (e *DeploymentError) Error() string {
+	return e.Message
+}
+
+// ResolveDeployments - Prepares deployments for reconciliation
+//
+// Versions of deployments without manifest version are detected, deployments whose version
+// can't be detected are flagged in error. Latest manifest version is rendered for each
+// deployment, deployments keep releases of the latest manifest version when rendering fails.
+func (a *Manager) ResolveDeployments(deployments []BoshDeploymentData, manifests []ManifestReleaseData) []error {
+	errs := []error{}
+	// snapshot deployments are already resolved
+	if a.snapshot != nil {
+		return errs
+	}
+	for idx := range deployments {
+		d := &deployments[idx]
+		if d.HasError {
+			continue
+		}
+		if d.Ref == "" {
+			if err := a.DetectVersion(d, manifests); err != nil {
+				d.HasError = true
+				errs = append(errs, &DeploymentError{
+					Deployment: d.Deployment,
+					Message:    fmt.Sprintf("unable to detect version of deployment '%s': %s", d.Deployment, err),
+				})
+				continue
+			}
+		}
+		manifest, version := FindVersion(*d, manifests)
+		if manifest == nil || version == nil {
+			continue
+		}
+		releases, err := a.GetDeploymentBoshReleases(*d, *manifest)
+		if err != nil {
+			errs = append(errs, &DeploymentError{
+				Deployment: d.Deployment,
+				Message:    fmt.Sprintf("unable to render manifest for deployment '%s': %s", d.Deployment, err),
+			})
+			continue
+		}
+		d.Recommended = releases
+	}
+	return errs
+}
+
+// Local Variables:
+// ispell-local-dictionary: "american"
+// End:
@@ -0,0 +1,149 @@
+package boshupdate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testManifests() []ManifestReleaseData {
+	return []ManifestReleaseData{
+		{
+			ManifestReleaseConfig: ManifestReleaseConfig{Matchers: []string{"^cf-deployment$"}},
+			Name:                  "cf",
+			Versions: []Version{
+				{Version: "2.0.0", Time: 2000},
+				{Version: "1.0.0", Time: 1000, ExpiredSince: 2000},
+			},
+			LatestVersion: Version{Version: "2.0.0", Time: 2000},
+			BoshReleases: []BoshRelease{
+				{Name: "capi", Version: "1.2"},
+				{Name: "diego", Version: "2.5"},
+				{Name: "bosh", Version: "280.0.0"},
+			},
+		},
+	}
+}
+
+func testGenerics() []GenericReleaseData {
+	return []GenericReleaseData{
+		{
+			GenericReleaseConfig: GenericReleaseConfig{BoshRelease: "bpm"},
+			Name:                 "bpm",
+			Versions: []Version{
+				{Version: "1.2.0", Time: 3000},
+				{Version: "1.1.0", Time: 1500, ExpiredSince: 3000},
+			},
+			LatestVersion: Version{Version: "1.2.0", Time: 3000},
+		},
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	deployments := []BoshDeploymentData{
+		{
+			Deployment:   "cf",
+			ManifestName: "cf-deployment",
+			Ref:          "1.0.0",
+			Detection:    DetectionManifest,
+			Confidence:   1,
+			BoshReleases: []BoshRelease{
+				{Name: "capi", Version: "1.1"},
+				{Name: "diego", Version: "2.5"},
+				{Name: "bpm", Version: "1.1.0"},
+				{Name: "custom", Version: "0.1"},
+			},
+		},
+		{Deployment: "broken", HasError: true},
+		{Deployment: "unknown", ManifestName: "unknown", Ref: "1.0.0"},
+	}
+
+	res := Reconcile(deployments, testManifests(), testGenerics())
+	if len(res) != 2 {
+		t.Fatalf("expected 2 statuses, got %d", len(res))
+	}
+
+	cf := res[0]
+	if cf.ManifestName != "cf" || cf.Current != "1.0.0" || cf.Latest != "2.0.0" || cf.OutdatedSince != 2000 {
+		t.Errorf("unexpected deployment status: %+v", cf)
+	}
+	expected := []ReleaseStatus{
+		{Name: "capi", Current: "1.1", Latest: "1.2", OutdatedSince: 2000},
+		{Name: "diego", Current: "2.5", Latest: "2.5", OutdatedSince: 0},
+		{Name: "bpm", Current: "1.1.0", Latest: "1.2.0", OutdatedSince: 3000},
+		{Name: "custom", Current: "0.1", Latest: NotFound, OutdatedSince: 0},
+	}
+	if !reflect.DeepEqual(cf.Releases, expected) {
+		t.Errorf("unexpected release statuses:\n got: %+v\nwant: %+v", cf.Releases, expected)
+	}
+
+	unknown := res[1]
+	if unknown.Latest != NotFound || unknown.OutdatedSince != 0 || len(unknown.Releases) != 0 {
+		t.Errorf("unexpected status of deployment without manifest release: %+v", unknown)
+	}
+}
+
+func TestReconcileRecommended(t *testing.T) {
+	deployments := []BoshDeploymentData{
+		{
+			Deployment:   "cf",
+			ManifestName: "cf-deployment",
+			Ref:          "2.0.0",
+			BoshReleases: []BoshRelease{
+				{Name: "windows", Version: "1.0"},
+			},
+			Recommended: []BoshRelease{
+				{Name: "windows", Version: "1.0"},
+			},
+		},
+	}
+
+	res := Reconcile(deployments, testManifests(), nil)
+	expected := []ReleaseStatus{
+		{Name: "windows", Current: "1.0", Latest: "1.0"},
+	}
+	if !reflect.DeepEqual(res[0].Releases, expected) {
+		t.Errorf("unexpected release statuses:\n got: %+v\nwant: %+v", res[0].Releases, expected)
+	}
+	if res[0].Releases[0].IsOutdated() {
+		t.Errorf("release recommended for the deployment should be up to date")
+	}
+}
+
+func TestReconcileConfig(t *testing.T) {
+	config := ConfigData{
+		Name: "cf-deployment",
+		Type: "runtime",
+		BoshReleases: []BoshRelease{
+			{Name: "capi", Version: "1.2"},
+			{Name: "diego", Version: "2.4"},
+			{Name: "bpm", Version: "0.9.0"},
+			{Name: "dns", Version: "1.0"},
+		},
+	}
+
+	res := ReconcileConfig(config, testManifests(), testGenerics())
+	expected := []ReleaseStatus{
+		{Name: "capi", Current: "1.2", Latest: "1.2", OutdatedSince: 0},
+		{Name: "diego", Current: "2.4", Latest: "2.5", OutdatedSince: 2000},
+		{Name: "bpm", Current: "0.9.0", Latest: "1.2.0", OutdatedSince: 3000},
+		{Name: "dns", Current: "1.0", Latest: NotFound, OutdatedSince: 0},
+	}
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("unexpected release statuses:\n got: %+v\nwant: %+v", res, expected)
+	}
+}
+
+func TestReconcileDirector(t *testing.T) {
+	director := DirectorData{Name: "bosh", Version: "279.0.0"}
+
+	rs := ReconcileDirector(director, "cf", testManifests(), testGenerics())
+	expected := ReleaseStatus{Name: "bosh", Current: "279.0.0", Latest: "280.0.0", OutdatedSince: 2000}
+	if rs != expected {
+		t.Errorf("unexpected director status: got %+v, want %+v", rs, expected)
+	}
+
+	rs = ReconcileDirector(director, "missing", testManifests(), testGenerics())
+	if rs.Latest != NotFound || rs.OutdatedSince != 0 {
+		t.Errorf("unexpected director status for unknown source: %+v", rs)
+	}
+}
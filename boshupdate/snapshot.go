@@ -0,0 +1,99 @@
+package boshupdate
+
+import (
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// Snapshot - Data fetched from GitHub and bosh director at a given time
+//
+// Deployments are stored once resolved so that detected versions and recommended
+// bosh releases are available without network access.
+type Snapshot struct {
+	Time             int64                 `yaml:"time"`
+	ManifestReleases []ManifestReleaseData `yaml:"manifest_releases"`
+	GenericReleases  []GenericReleaseData  `yaml:"generic_releases"`
+	Deployments      []BoshDeploymentData  `yaml:"deployments"`
+	// deployment manifests, keyed by deployment name
+	Manifests map[string]string `yaml:"manifests"`
+	Configs   []ConfigData      `yaml:"configs,omitempty"`
+	Director  *DirectorData     `yaml:"director,omitempty"`
+	Assets    []AssetData       `yaml:"assets,omitempty"`
+}
+
+// TakeSnapshot - Fetches and resolves data selected by filter, only failing when deployments
+// are unavailable
+func (a *Manager) TakeSnapshot(filter Filter) (*Snapshot, error) {
+	res := &Snapshot{
+		Time:             time.Now().Unix(),
+		ManifestReleases: a.GetManifestReleasesFiltered(filter),
+		GenericReleases:  a.GetGenericReleasesFiltered(filter),
+		Manifests:        map[string]string{},
+	}
+
+	deployments, err := a.GetBoshDeploymentsFiltered(filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, err := range a.ResolveDeployments(deployments, res.ManifestReleases) {
+		log.Warnf("error during analysis of deployment: %s", err)
+	}
+	for _, d := range deployments {
+		if d.Manifest != "" {
+			res.Manifests[d.Deployment] = d.Manifest
+		}
+	}
+	res.Deployments = deployments
+
+	if res.Configs, err = a.GetConfigs(); err != nil {
+		log.Warnf("%s", err)
+	}
+	if res.Director, err = a.GetDirector(); err != nil {
+		log.Warnf("%s", err)
+	}
+	if res.Assets, err = a.GetAssets(); err != nil {
+		log.Warnf("%s", err)
+	}
+	return res, nil
+}
+
+// Write - Saves snapshot to given file
+func (s *Snapshot) Write(path string) error {
+	content, err := yaml.Marshal(s)
+	if err != nil {
+		return errors.Wrapf(err, "unable to serialize snapshot")
+	}
+	if err = os.WriteFile(path, content, 0600); err != nil {
+		return errors.Wrapf(err, "unable to write snapshot '%s'", path)
+	}
+	return nil
+}
+
+// LoadSnapshot - Reads snapshot from given file and restores deployment manifests
+func LoadSnapshot(path string) (*Snapshot, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read snapshot '%s'", path)
+	}
+	res := &Snapshot{}
+	if err = yaml.Unmarshal(content, res); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse snapshot '%s'", path)
+	}
+	for idx := range res.Deployments {
+		res.Deployments[idx].Manifest = res.Manifests[res.Deployments[idx].Deployment]
+	}
+	return res, nil
+}
+
+// UseSnapshot - Serves data of given snapshot instead of querying GitHub and bosh director
+func (a *Manager) UseSnapshot(snapshot *Snapshot) {
+	a.snapshot = snapshot
+}
+
+// Local Variables:
+// ispell-local-dictionary: "american"
+// End:
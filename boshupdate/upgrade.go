@@ -0,0 +1,113 @@
+package boshupdate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/cppforlife/go-patch/patch"
+	"github.com/orange-cloudfoundry/boshupdate_exporter/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// bosh.io releases of a github repository, variable to be overridden by tests
+	boshioReleasesURL = "https://bosh.io/api/v1/releases/github.com/%s/%s"
+)
+
+// boshioRelease - Release version published on bosh.io
+type boshioRelease struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	URL     string `json:"url"`
+	SHA1    string `json:"sha1"`
+}
+
+// UpgradeOps - Gives go-patch operations replacing outdated bosh releases of given deployment
+// by their latest version
+//
+//  1. Bosh releases are compared to releases recommended for the deployment when resolved,
+//     to releases of the latest version of matching manifest release otherwise.
+//  2. Bosh releases missing from the manifest are compared to the generic release declaring
+//     the same bosh_release, whose url and sha1 are fetched from bosh.io. Releases unknown
+//     to bosh.io are skipped.
+func (a *Manager) UpgradeOps(deployment BoshDeploymentData, manifests []ManifestReleaseData, generics []GenericReleaseData) ([]patch.OpDefinition, error) {
+	entry := log.WithField("deployment", deployment.Deployment)
+
+	// 1.
+	recommended := deployment.Recommended
+	if recommended == nil {
+		for _, m := range manifests {
+			if !m.HasError && m.Match(deployment.ManifestName) {
+				recommended = m.BoshReleases
+				break
+			}
+		}
+	}
+
+	res := []patch.OpDefinition{}
+	for _, br := range deployment.BoshReleases {
+		latest := findBoshRelease(recommended, br.Name)
+		// 2.
+		if latest == nil {
+			for _, g := range generics {
+				if g.HasError || g.BoshRelease != br.Name || g.LatestVersion.Version == br.Version {
+					continue
+				}
+				release, err := a.getBoshioRelease(g, g.LatestVersion.Version)
+				if err != nil {
+					entry.Warnf("skipping bosh release '%s': %s", br.Name, err)
+					break
+				}
+				latest = &BoshRelease{Name: br.Name, Version: release.Version, URL: release.URL, SHA1: release.SHA1}
+				break
+			}
+		}
+		if latest == nil || latest.Version == br.Version {
+			continue
+		}
+
+		path := fmt.Sprintf("/releases/name=%s", br.Name)
+		var value interface{} = BoshRelease{Name: br.Name, Version: latest.Version, URL: latest.URL, SHA1: latest.SHA1}
+		res = append(res, patch.OpDefinition{Type: "replace", Path: &path, Value: &value})
+	}
+	return res, nil
+}
+
+// getBoshioRelease - Fetches given version of bosh release tracked by generic release from bosh.io
+//
+// The github http client is not used, its credentials must not be sent to bosh.io.
+func (a *Manager) getBoshioRelease(item GenericReleaseData, version string) (*boshioRelease, error) {
+	url := fmt.Sprintf(boshioReleasesURL, item.Owner, item.Repo)
+	client := http.Client{Timeout: time.Minute}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not download '%s'", url)
+	}
+	defer utils.CloseAndLogError(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not download '%s': %s", url, resp.Status)
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could read remote stream")
+	}
+
+	releases := []boshioRelease{}
+	if err = json.Unmarshal(content, &releases); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse bosh.io releases of %s/%s", item.Owner, item.Repo)
+	}
+	for _, r := range releases {
+		if r.Version == version {
+			return &r, nil
+		}
+	}
+	return nil, fmt.Errorf("unable to find version '%s' of %s/%s on bosh.io", version, item.Owner, item.Repo)
+}
+
+// Local Variables:
+// ispell-local-dictionary: "american"
+// End:
@@ -0,0 +1,214 @@
+package boshupdate
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func newBoshioServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/github.com/cloudfoundry/nats-release":
+			fmt.Fprint(w, `[
+				{"name": "github.com/cloudfoundry/nats-release", "version": "2.0", "url": "https://bosh.io/d/nats-2.0", "sha1": "abc"},
+				{"name": "github.com/cloudfoundry/nats-release", "version": "1.0", "url": "https://bosh.io/d/nats-1.0", "sha1": "def"}
+			]`)
+		case "/github.com/cloudfoundry/invalid-release":
+			fmt.Fprint(w, `{"name": "invalid"}`)
+		case "/github.com/cloudfoundry/broken-release":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	url := boshioReleasesURL
+	boshioReleasesURL = server.URL + "/github.com/%s/%s"
+	t.Cleanup(func() { boshioReleasesURL = url })
+}
+
+func TestGetBoshioRelease(t *testing.T) {
+	newBoshioServer(t)
+	m := &Manager{}
+
+	tests := []struct {
+		name          string
+		repo          string
+		version       string
+		expected      string
+		expectedError bool
+	}{
+		{name: "found", repo: "nats-release", version: "1.0", expected: "https://bosh.io/d/nats-1.0"},
+		{name: "no matching version", repo: "nats-release", version: "3.0", expectedError: true},
+		{name: "unknown release", repo: "unknown-release", version: "1.0", expectedError: true},
+		{name: "server error", repo: "broken-release", version: "1.0", expectedError: true},
+		{name: "invalid content", repo: "invalid-release", version: "1.0", expectedError: true},
+	}
+
+	for _, tt := range tests {
+		item := GenericReleaseData{GenericReleaseConfig: GenericReleaseConfig{Owner: "cloudfoundry", Repo: tt.repo}}
+		res, err := m.getBoshioRelease(item, tt.version)
+		if tt.expectedError {
+			if err == nil {
+				t.Errorf("%s: expected error, got %+v", tt.name, res)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.name, err)
+			continue
+		}
+		if res.URL != tt.expected {
+			t.Errorf("%s: expected url %s, got %s", tt.name, tt.expected, res.URL)
+		}
+	}
+}
+
+func TestUpgradeOps(t *testing.T) {
+	newBoshioServer(t)
+	m := &Manager{}
+
+	generic := func(repo string, boshRelease string, latest string) GenericReleaseData {
+		return GenericReleaseData{
+			GenericReleaseConfig: GenericReleaseConfig{Owner: "cloudfoundry", Repo: repo, BoshRelease: boshRelease},
+			LatestVersion:        Version{Version: latest},
+		}
+	}
+	manifests := []ManifestReleaseData{
+		{
+			ManifestReleaseConfig: ManifestReleaseConfig{Matchers: []string{"cf(-.*)?"}},
+			HasError:              true,
+			BoshReleases:          []BoshRelease{{Name: "capi", Version: "9.9"}},
+		},
+		{
+			ManifestReleaseConfig: ManifestReleaseConfig{Matchers: []string{"cf(-.*)?"}},
+			BoshReleases: []BoshRelease{
+				{Name: "capi", Version: "1.1", URL: "https://bosh.io/d/capi-1.1", SHA1: "123"},
+				{Name: "diego", Version: "2.0"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		deployment  BoshDeploymentData
+		generics    []GenericReleaseData
+		expectedOps []BoshRelease
+	}{
+		{
+			name:        "empty",
+			deployment:  BoshDeploymentData{Deployment: "cf", ManifestName: "cf"},
+			expectedOps: []BoshRelease{},
+		},
+		{
+			name: "latest version of matching manifest",
+			deployment: BoshDeploymentData{
+				Deployment:   "cf",
+				ManifestName: "cf",
+				BoshReleases: []BoshRelease{{Name: "capi", Version: "1.0"}, {Name: "diego", Version: "2.0"}},
+			},
+			expectedOps: []BoshRelease{
+				{Name: "capi", Version: "1.1", URL: "https://bosh.io/d/capi-1.1", SHA1: "123"},
+			},
+		},
+		{
+			name: "recommended releases",
+			deployment: BoshDeploymentData{
+				Deployment:   "cf",
+				ManifestName: "cf",
+				BoshReleases: []BoshRelease{{Name: "capi", Version: "1.0"}, {Name: "diego", Version: "2.0"}},
+				Recommended:  []BoshRelease{{Name: "capi", Version: "1.0"}, {Name: "diego", Version: "2.1"}},
+			},
+			expectedOps: []BoshRelease{
+				{Name: "diego", Version: "2.1"},
+			},
+		},
+		{
+			name: "bosh.io fallback",
+			deployment: BoshDeploymentData{
+				Deployment:   "cf",
+				ManifestName: "cf",
+				BoshReleases: []BoshRelease{{Name: "capi", Version: "1.1"}, {Name: "nats", Version: "1.0"}},
+			},
+			generics: []GenericReleaseData{
+				generic("capi-release", "capi", "1.2"),
+				generic("nats-release", "nats", "2.0"),
+			},
+			expectedOps: []BoshRelease{
+				{Name: "nats", Version: "2.0", URL: "https://bosh.io/d/nats-2.0", SHA1: "abc"},
+			},
+		},
+		{
+			name: "no matching manifest",
+			deployment: BoshDeploymentData{
+				Deployment:   "other",
+				ManifestName: "other",
+				BoshReleases: []BoshRelease{{Name: "capi", Version: "1.0"}, {Name: "nats", Version: "1.0"}},
+			},
+			generics: []GenericReleaseData{
+				generic("nats-release", "nats", "2.0"),
+			},
+			expectedOps: []BoshRelease{
+				{Name: "nats", Version: "2.0", URL: "https://bosh.io/d/nats-2.0", SHA1: "abc"},
+			},
+		},
+		{
+			name: "generic release up to date",
+			deployment: BoshDeploymentData{
+				Deployment:   "other",
+				ManifestName: "other",
+				BoshReleases: []BoshRelease{{Name: "nats", Version: "2.0"}},
+			},
+			generics: []GenericReleaseData{
+				generic("broken-release", "nats", "2.0"),
+			},
+			expectedOps: []BoshRelease{},
+		},
+		{
+			name: "skipped generic releases",
+			deployment: BoshDeploymentData{
+				Deployment:   "other",
+				ManifestName: "other",
+				BoshReleases: []BoshRelease{
+					{Name: "nats", Version: "1.0"},
+					{Name: "broken", Version: "1.0"},
+					{Name: "unknown", Version: "1.0"},
+					{Name: "custom", Version: "1.0"},
+				},
+			},
+			generics: []GenericReleaseData{
+				generic("nats-release", "nats", "3.0"),
+				generic("broken-release", "broken", "2.0"),
+				generic("unknown-release", "unknown", "2.0"),
+				{
+					GenericReleaseConfig: GenericReleaseConfig{Owner: "cloudfoundry", Repo: "nats-release", BoshRelease: "custom"},
+					HasError:             true,
+				},
+			},
+			expectedOps: []BoshRelease{},
+		},
+	}
+
+	for _, tt := range tests {
+		ops, err := m.UpgradeOps(tt.deployment, manifests, tt.generics)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.name, err)
+			continue
+		}
+		res := []BoshRelease{}
+		for _, op := range ops {
+			release := (*op.Value).(BoshRelease)
+			if op.Type != "replace" || *op.Path != "/releases/name="+release.Name {
+				t.Errorf("%s: unexpected operation '%s' on '%s'", tt.name, op.Type, *op.Path)
+			}
+			res = append(res, release)
+		}
+		if !reflect.DeepEqual(res, tt.expectedOps) {
+			t.Errorf("%s: unexpected releases:\n got: %+v\nwant: %+v", tt.name, res, tt.expectedOps)
+		}
+	}
+}
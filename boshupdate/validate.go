@@ -0,0 +1,116 @@
+package boshupdate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/github"
+	log "github.com/sirupsen/logrus"
+)
+
+// ValidateSources - Exercises director and GitHub sources of configuration, giving all
+// issues found
+//
+//  1. Director info doesn't require authentication, listing deployments does. Director is
+//     connected directly, bypassing backoff of the manager which would hide auth errors.
+//  2. Refs are already filtered by format, none left means that format.match never matches.
+//  3. Manifest, ops-files, vars-files and ops_dir of manifest releases, including those of
+//     overrides, must exist at the latest ref.
+func (a *Manager) ValidateSources() []error {
+	errs := []error{}
+
+	// 1.
+	log.WithField("name", "director").Debugf("validating director authentication")
+	if err := validateDirector(a.config.Bosh); err != nil {
+		errs = append(errs, fmt.Errorf("director '%s': %s", a.config.Bosh.URL, err))
+	}
+
+	for _, name := range sortedNames(a.config.Github.ManifestReleases) {
+		item := a.config.Github.ManifestReleases[name]
+		ref, err := a.validateRelease(item.GenericReleaseConfig)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("manifest release '%s': %s", name, err))
+			continue
+		}
+		// 3.
+		for _, path := range manifestPaths(*item) {
+			if err := a.validatePath(ref, item.GenericReleaseConfig, path); err != nil {
+				errs = append(errs, fmt.Errorf("manifest release '%s': %s", name, err))
+			}
+		}
+	}
+
+	for _, name := range sortedNames(a.config.Github.GenericReleases) {
+		if _, err := a.validateRelease(*a.config.Github.GenericReleases[name]); err != nil {
+			errs = append(errs, fmt.Errorf("generic release '%s': %s", name, err))
+		}
+	}
+	return errs
+}
+
+// validateDirector - Connects to director and lists deployments, giving underlying error
+func validateDirector(config BoshConfig) error {
+	client, err := NewDirector(config)
+	if err != nil {
+		return err
+	}
+	_, err = client.Deployments()
+	return err
+}
+
+// validateRelease - Checks that repository exists and has refs matching format, gives latest ref
+func (a *Manager) validateRelease(item GenericReleaseConfig) (string, error) {
+	entry := log.WithFields(log.Fields{
+		"repo":  item.Repo,
+		"owner": item.Owner,
+	})
+	entry.Debugf("validating repository")
+	if _, _, err := a.client.Repositories.Get(a.ctx, item.Owner, item.Repo); err != nil {
+		return "", fmt.Errorf("unable to find repository %s/%s: %s", item.Owner, item.Repo, checkRateLimit(err))
+	}
+
+	// 2.
+	refs, err := a.getRefs(item, nil)
+	if err != nil {
+		return "", err
+	}
+	if len(refs) == 0 {
+		return "", fmt.Errorf("format.match '%s' doesn't match any %s of %s/%s",
+			item.Format.Match, strings.Join(item.Types, ", "), item.Owner, item.Repo)
+	}
+	return refs[0].Ref, nil
+}
+
+// validatePath - Checks that file or directory exists at given ref
+func (a *Manager) validatePath(ref string, item GenericReleaseConfig, path string) error {
+	opts := github.RepositoryContentGetOptions{Ref: ref}
+	if _, _, _, err := a.client.Repositories.GetContents(a.ctx, item.Owner, item.Repo, path, &opts); err != nil {
+		return fmt.Errorf("unable to find '%s' at ref '%s': %s", path, ref, checkRateLimit(err))
+	}
+	return nil
+}
+
+// manifestPaths - Gives distinct remote paths referenced by manifest release
+func manifestPaths(item ManifestReleaseConfig) []string {
+	paths := []string{item.Manifest, item.OpsDir}
+	paths = append(paths, item.Ops...)
+	paths = append(paths, item.Vars...)
+	for _, o := range item.Overrides {
+		paths = append(paths, o.Ops...)
+		paths = append(paths, o.Vars...)
+	}
+
+	res := []string{}
+	seen := map[string]bool{}
+	for _, p := range paths {
+		if len(p) != 0 && !seen[p] {
+			seen[p] = true
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// Local Variables:
+// ispell-local-dictionary: "american"
+// End:
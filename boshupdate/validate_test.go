@@ -0,0 +1,48 @@
+package boshupdate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestManifestPaths(t *testing.T) {
+	tests := []struct {
+		name     string
+		item     ManifestReleaseConfig
+		expected []string
+	}{
+		{
+			name:     "no manifest",
+			item:     ManifestReleaseConfig{},
+			expected: []string{},
+		},
+		{
+			name: "manifest ops and vars",
+			item: ManifestReleaseConfig{
+				Manifest: "cf-deployment.yml",
+				OpsDir:   "operations",
+				Ops:      []string{"operations/scale.yml"},
+				Vars:     []string{"vars.yml"},
+			},
+			expected: []string{"cf-deployment.yml", "operations", "operations/scale.yml", "vars.yml"},
+		},
+		{
+			name: "overrides without duplicates",
+			item: ManifestReleaseConfig{
+				Manifest: "cf-deployment.yml",
+				Ops:      []string{"operations/scale.yml"},
+				Overrides: []ManifestOverrideConfig{
+					{Ops: []string{"operations/scale.yml", "operations/tls.yml"}, Vars: []string{"vars.yml"}},
+					{Ops: []string{"operations/tls.yml"}},
+				},
+			},
+			expected: []string{"cf-deployment.yml", "operations/scale.yml", "operations/tls.yml", "vars.yml"},
+		},
+	}
+
+	for _, tt := range tests {
+		if res := manifestPaths(tt.item); !reflect.DeepEqual(res, tt.expected) {
+			t.Errorf("%s: expected paths %v, got %v", tt.name, tt.expected, res)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package boshupdate
+
+import (
+	"regexp"
+	"strings"
+
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+)
+
+var (
+	// same expression used by bosh-cli to detect variables
+	interpolationRegex = regexp.MustCompile(`\(\((!?[-/\.\w\pL]+)\)\)`)
+)
+
+// variableName - Strip bosh-cli prefixes from variable reference
+func variableName(ref string) string {
+	return strings.TrimPrefix(ref, "!")
+}
+
+// extractVariables - Recovers variable values by walking template alongside actual manifest
+//
+//  1. Values still containing a variable reference were not interpolated, most likely
+//     because they are managed by the director config server
+//  2. Dotted names address sub-keys of a credential and can't be recovered as a whole
+//  3. Lists of named objects are matched by name, other lists by position
+func extractVariables(tpl interface{}, actual interface{}, excludes map[string]bool, vars boshtpl.StaticVariables) {
+	switch typedTpl := tpl.(type) {
+	case map[interface{}]interface{}:
+		typedActual, ok := actual.(map[interface{}]interface{})
+		if !ok {
+			return
+		}
+		for k, v := range typedTpl {
+			if av, found := typedActual[k]; found {
+				extractVariables(v, av, excludes, vars)
+			}
+		}
+
+	case []interface{}:
+		typedActual, ok := actual.([]interface{})
+		if !ok {
+			return
+		}
+		for idx, v := range typedTpl {
+			// 3.
+			if name, ok := namedItem(v); ok {
+				for _, av := range typedActual {
+					if aname, ok := namedItem(av); ok && aname == name {
+						extractVariables(v, av, excludes, vars)
+						break
+					}
+				}
+				continue
+			}
+			if idx < len(typedActual) {
+				extractVariables(v, typedActual[idx], excludes, vars)
+			}
+		}
+
+	case string:
+		matches := interpolationRegex.FindAllStringSubmatchIndex(typedTpl, -1)
+		if len(matches) == 0 {
+			return
+		}
+		// 1.
+		if str, ok := actual.(string); ok && interpolationRegex.MatchString(str) {
+			return
+		}
+
+		if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(typedTpl) {
+			setVariable(typedTpl[matches[0][2]:matches[0][3]], actual, excludes, vars)
+			return
+		}
+
+		str, ok := actual.(string)
+		if !ok {
+			return
+		}
+		pattern := "\\A"
+		names := []string{}
+		last := 0
+		for _, m := range matches {
+			pattern += regexp.QuoteMeta(typedTpl[last:m[0]]) + "(.+?)"
+			names = append(names, typedTpl[m[2]:m[3]])
+			last = m[1]
+		}
+		pattern += regexp.QuoteMeta(typedTpl[last:]) + "\\z"
+		values := regexp.MustCompile(pattern).FindStringSubmatch(str)
+		if values == nil {
+			return
+		}
+		for idx, name := range names {
+			setVariable(name, values[idx+1], excludes, vars)
+		}
+	}
+}
+
+func setVariable(ref string, value interface{}, excludes map[string]bool, vars boshtpl.StaticVariables) {
+	name := variableName(ref)
+	// 2.
+	if strings.Contains(name, ".") || excludes[name] {
+		return
+	}
+	if _, found := vars[name]; !found {
+		vars[name] = value
+	}
+}
+
+func namedItem(item interface{}) (string, bool) {
+	m, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return "", false
+	}
+	name, ok := m["name"].(string)
+	if !ok || interpolationRegex.MatchString(name) {
+		return "", false
+	}
+	return name, true
+}
+
+// Local Variables:
+// ispell-local-dictionary: "american"
+// End:
@@ -0,0 +1,106 @@
+package boshupdate
+
+import (
+	"reflect"
+	"testing"
+
+	boshtpl "github.com/cloudfoundry/bosh-cli/director/template"
+	"gopkg.in/yaml.v2"
+)
+
+func TestExtractVariables(t *testing.T) {
+	tests := []struct {
+		name     string
+		tpl      string
+		actual   string
+		excludes map[string]bool
+		expected boshtpl.StaticVariables
+	}{
+		{
+			name:     "no variable",
+			tpl:      "name: cf",
+			actual:   "name: cf",
+			expected: boshtpl.StaticVariables{},
+		},
+		{
+			name:     "whole values",
+			tpl:      "domain: ((system_domain))\ninstances: ((!count))",
+			actual:   "domain: sys.example.com\ninstances: 3",
+			expected: boshtpl.StaticVariables{"system_domain": "sys.example.com", "count": 3},
+		},
+		{
+			name:     "embedded in strings",
+			tpl:      "url: https://api.((system_domain)):((port))/v2",
+			actual:   "url: https://api.sys.example.com:443/v2",
+			expected: boshtpl.StaticVariables{"system_domain": "sys.example.com", "port": "443"},
+		},
+		{
+			name:     "string not matching template",
+			tpl:      "url: https://api.((system_domain))",
+			actual:   "url: http://other",
+			expected: boshtpl.StaticVariables{},
+		},
+		{
+			name:     "config server values",
+			tpl:      "password: ((admin_password))",
+			actual:   "password: ((/bosh/cf/admin_password))",
+			expected: boshtpl.StaticVariables{},
+		},
+		{
+			name:     "excluded and credential sub-keys",
+			tpl:      "password: ((admin_password))\ncert: ((router_ssl.certificate))",
+			actual:   "password: secret\ncert: pem",
+			excludes: map[string]bool{"admin_password": true},
+			expected: boshtpl.StaticVariables{},
+		},
+		{
+			name:     "first value wins",
+			tpl:      "domains: [((domain)), \"api.((domain))\"]",
+			actual:   "domains: [a.example.com, api.b.example.com]",
+			expected: boshtpl.StaticVariables{"domain": "a.example.com"},
+		},
+		{
+			name:     "named lists matched by name",
+			tpl:      "instance_groups:\n- name: api\n  instances: ((api_instances))\n- name: router\n  instances: ((router_instances))",
+			actual:   "instance_groups:\n- name: router\n  instances: 2\n- name: api\n  instances: 4",
+			expected: boshtpl.StaticVariables{"api_instances": 4, "router_instances": 2},
+		},
+		{
+			name:     "named lists missing item",
+			tpl:      "instance_groups:\n- name: api\n  instances: ((api_instances))",
+			actual:   "instance_groups:\n- name: router\n  instances: 2",
+			expected: boshtpl.StaticVariables{},
+		},
+		{
+			name:     "other lists matched by position",
+			tpl:      "azs: [((az1)), ((az2)), ((az3))]",
+			actual:   "azs: [z1, z2]",
+			expected: boshtpl.StaticVariables{"az1": "z1", "az2": "z2"},
+		},
+		{
+			name:     "mismatching types",
+			tpl:      "properties:\n  domain: ((domain))",
+			actual:   "properties: [a, b]",
+			expected: boshtpl.StaticVariables{},
+		},
+	}
+
+	for _, tt := range tests {
+		var tpl, actual interface{}
+		if err := yaml.Unmarshal([]byte(tt.tpl), &tpl); err != nil {
+			t.Fatalf("%s: invalid template: %s", tt.name, err)
+		}
+		if err := yaml.Unmarshal([]byte(tt.actual), &actual); err != nil {
+			t.Fatalf("%s: invalid manifest: %s", tt.name, err)
+		}
+		excludes := tt.excludes
+		if excludes == nil {
+			excludes = map[string]bool{}
+		}
+		vars := boshtpl.StaticVariables{}
+		extractVariables(tpl, actual, excludes, vars)
+		if !reflect.DeepEqual(vars, tt.expected) {
+			t.Errorf("%s: expected variables %v, got %v", tt.name, tt.expected, vars)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/orange-cloudfoundry/boshupdate_exporter/boshupdate"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+func printChangelog(res boshupdate.DeploymentChangelog, output string) {
+	switch output {
+	case "json":
+		content, _ := json.MarshalIndent(res, "", "  ")
+		fmt.Println(string(content))
+
+	case "yaml":
+		content, _ := yaml.Marshal(res)
+		fmt.Println(string(content))
+
+	default:
+		fmt.Printf("%s (%s): %s -> %s\n", res.Deployment, res.ManifestName, res.From, res.To)
+		if len(res.Versions) == 0 {
+			fmt.Println("\nalready up to date")
+		}
+		for _, v := range res.Versions {
+			fmt.Printf("\n## %s (%s)", v.Version, time.Unix(v.Time, 0).Format("2006-01-02"))
+			if v.Notes == nil {
+				fmt.Println("\n\nno release notes")
+				continue
+			}
+			if len(v.Notes.Keywords) != 0 {
+				fmt.Printf(" [%s]", strings.ToUpper(strings.Join(v.Notes.Keywords, ", ")))
+			}
+			fmt.Printf("\n%s\n", v.Notes.URL)
+			if body := strings.TrimSpace(v.Notes.Body); body != "" {
+				fmt.Printf("\n%s\n", body)
+			}
+		}
+	}
+}
+
+func changelog(manager *boshupdate.Manager, name string, output string) {
+	manifests := manager.GetManifestReleasesFiltered(filter)
+	deployment := findDeployment(manager, name, manifests)
+
+	manifest, _ := boshupdate.FindVersion(*deployment, manifests)
+	if manifest == nil {
+		log.Errorf("unable to find manifest release matching deployment '%s' at version '%s'", name, deployment.Ref)
+		os.Exit(1)
+	}
+	printChangelog(boshupdate.Changelog(*deployment, *manifest), output)
+}
+
+// Local Variables:
+// ispell-local-dictionary: "american"
+// End:
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/orange-cloudfoundry/boshupdate_exporter/boshupdate"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	levelWarning   = "warning"
+	levelViolation = "error"
+
+	ruleDaysOutdated   = "days-outdated"
+	ruleVersionsBehind = "versions-behind"
+	ruleForbidden      = "forbidden-release"
+	ruleUnavailable    = "data-unavailable"
+
+	// exit codes, 1 being used for runtime errors
+	exitViolation = 2
+	exitWarning   = 3
+)
+
+var (
+	ruleDescriptions = map[string]string{
+		ruleDaysOutdated:   "Deployment is outdated for too many days",
+		ruleVersionsBehind: "Deployment is too many versions behind the latest manifest version",
+		ruleForbidden:      "Deployment uses a forbidden bosh release",
+		ruleUnavailable:    "Deployment or release data could not be read from director or GitHub",
+	}
+	// rules evaluated for each deployment
+	deploymentRules = []string{ruleDaysOutdated, ruleVersionsBehind, ruleForbidden, ruleUnavailable}
+)
+
+// checkPolicy - Thresholds of check subcommand, zero disables a threshold
+type checkPolicy struct {
+	WarnDays      int
+	MaxDays       int
+	WarnVersions  int
+	MaxVersions   int
+	ForbiddenRels []*regexp.Regexp
+}
+
+// finding - Policy rule broken by a deployment, or by a release for data-unavailable rule
+type finding struct {
+	Deployment string
+	Rule       string
+	Level      string
+	Message    string
+}
+
+// checkThreshold - Gives level of value compared to warning and violation thresholds
+func checkThreshold(value int, warn int, max int) string {
+	switch {
+	case max > 0 && value > max:
+		return levelViolation
+	case warn > 0 && value > warn:
+		return levelWarning
+	}
+	return ""
+}
+
+func evaluate(statuses []boshupdate.DeploymentStatus, manifests []boshupdate.ManifestReleaseData, policy checkPolicy) []finding {
+	res := []finding{}
+	for _, s := range statuses {
+		if s.OutdatedSince != 0 {
+			days := int(time.Since(time.Unix(s.OutdatedSince, 0)).Hours() / 24)
+			if level := checkThreshold(days, policy.WarnDays, policy.MaxDays); level != "" {
+				res = append(res, finding{s.Deployment, ruleDaysOutdated, level,
+					fmt.Sprintf("version %s is outdated for %d days, latest is %s", s.Current, days, s.Latest)})
+			}
+		}
+
+		if manifest, _ := boshupdate.FindVersion(s.Data, manifests); manifest != nil {
+			behind := len(boshupdate.Changelog(s.Data, *manifest).Versions)
+			if level := checkThreshold(behind, policy.WarnVersions, policy.MaxVersions); level != "" {
+				res = append(res, finding{s.Deployment, ruleVersionsBehind, level,
+					fmt.Sprintf("version %s is %d versions behind latest %s", s.Current, behind, s.Latest)})
+			}
+		}
+
+		for _, br := range s.Data.BoshReleases {
+			for _, re := range policy.ForbiddenRels {
+				if re.MatchString(br.Name + "/" + br.Version) {
+					res = append(res, finding{s.Deployment, ruleForbidden, levelViolation,
+						fmt.Sprintf("bosh release %s/%s matches forbidden pattern '%s'", br.Name, br.Version, re.String())})
+				}
+			}
+		}
+	}
+	return res
+}
+
+// unavailable - Reports deployments and releases whose data could not be read
+//
+// Reconciliation ignores them, a broken source must not pass the check. Deployments are
+// reported once, with their resolution error when known.
+func unavailable(deployments []boshupdate.BoshDeploymentData, manifests []boshupdate.ManifestReleaseData, generics []boshupdate.GenericReleaseData, errs []error) []finding {
+	res := []finding{}
+	reported := map[string]bool{}
+	for _, err := range errs {
+		var derr *boshupdate.DeploymentError
+		if !errors.As(err, &derr) {
+			continue
+		}
+		reported[derr.Deployment] = true
+		res = append(res, finding{derr.Deployment, ruleUnavailable, levelViolation, derr.Message})
+	}
+	for _, d := range deployments {
+		if d.HasError && !reported[d.Deployment] {
+			res = append(res, finding{d.Deployment, ruleUnavailable, levelViolation,
+				"unable to read deployment from director"})
+		}
+	}
+	for _, m := range manifests {
+		if m.HasError {
+			res = append(res, finding{"manifest/" + m.Name, ruleUnavailable, levelViolation,
+				fmt.Sprintf("unable to read manifest release '%s' from GitHub", m.Name)})
+		}
+	}
+	for _, g := range generics {
+		if g.HasError {
+			res = append(res, finding{"generic/" + g.Name, ruleUnavailable, levelViolation,
+				fmt.Sprintf("unable to read generic release '%s' from GitHub", g.Name)})
+		}
+	}
+	return res
+}
+
+// exitCode - Gives exit status of check subcommand, violations prevailing over warnings
+func exitCode(findings []finding) int {
+	code := 0
+	for _, f := range findings {
+		if f.Level == levelViolation {
+			return exitViolation
+		}
+		code = exitWarning
+	}
+	return code
+}
+
+type junitFailure struct {
+	Type    string `xml:"type,attr"`
+	Message string `xml:"message,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+// junitSuite - One test case per deployment and rule, warnings are reported as output
+// of passing test cases
+//
+// Deployments and releases missing from statuses, their data being unavailable, only
+// get a test case for data-unavailable rule.
+func junitSuite(statuses []boshupdate.DeploymentStatus, findings []finding) junitTestSuite {
+	type subject struct {
+		name  string
+		rules []string
+	}
+	subjects := []subject{}
+	seen := map[string]bool{}
+	for _, s := range statuses {
+		seen[s.Deployment] = true
+		subjects = append(subjects, subject{s.Deployment, deploymentRules})
+	}
+	for _, f := range findings {
+		if !seen[f.Deployment] {
+			seen[f.Deployment] = true
+			subjects = append(subjects, subject{f.Deployment, []string{ruleUnavailable}})
+		}
+	}
+
+	suite := junitTestSuite{Name: "boshupdate"}
+	for _, s := range subjects {
+		for _, rule := range s.rules {
+			tc := junitTestCase{Name: rule, ClassName: s.name}
+			for _, f := range findings {
+				if f.Deployment != s.name || f.Rule != rule {
+					continue
+				}
+				if f.Level == levelWarning {
+					tc.SystemOut += "warning: " + f.Message + "\n"
+					continue
+				}
+				if tc.Failure == nil {
+					tc.Failure = &junitFailure{Type: rule}
+					suite.Failures++
+				} else {
+					tc.Failure.Message += "; "
+				}
+				tc.Failure.Message += f.Message
+			}
+			suite.TestCases = append(suite.TestCases, tc)
+		}
+	}
+	suite.Tests = len(suite.TestCases)
+	return suite
+}
+
+func printJUnit(statuses []boshupdate.DeploymentStatus, findings []finding) {
+	content, _ := xml.MarshalIndent(junitSuite(statuses, findings), "", "  ")
+	fmt.Println(xml.Header + string(content))
+}
+
+// printSARIF - SARIF 2.1.0 log, deployments being reported as logical locations
+func printSARIF(findings []finding) {
+	rules := []map[string]interface{}{}
+	for _, id := range deploymentRules {
+		rules = append(rules, map[string]interface{}{
+			"id":               id,
+			"shortDescription": map[string]string{"text": ruleDescriptions[id]},
+		})
+	}
+	results := []map[string]interface{}{}
+	for _, f := range findings {
+		results = append(results, map[string]interface{}{
+			"ruleId":  f.Rule,
+			"level":   f.Level,
+			"message": map[string]string{"text": f.Message},
+			"locations": []interface{}{
+				map[string]interface{}{
+					"logicalLocations": []interface{}{
+						map[string]string{"name": f.Deployment, "kind": "module"},
+					},
+				},
+			},
+		})
+	}
+	sarif := map[string]interface{}{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []interface{}{
+			map[string]interface{}{
+				"tool": map[string]interface{}{
+					"driver": map[string]interface{}{
+						"name":  "boshupdate_cli",
+						"rules": rules,
+					},
+				},
+				"results": results,
+			},
+		},
+	}
+	content, _ := json.MarshalIndent(sarif, "", "  ")
+	fmt.Println(string(content))
+}
+
+// check - Evaluates deployments against policy, exits with status 2 on violations and 3
+// on warnings only
+//
+// Deployments and releases whose data could not be read are violations.
+func check(manager *boshupdate.Manager, policy checkPolicy, format string) {
+	manifests := manager.GetManifestReleasesFiltered(filter)
+	generics := manager.GetGenericReleasesFiltered(filter)
+	deployments, err := manager.GetBoshDeploymentsFiltered(filter)
+	if err != nil {
+		log.Errorf("unable to fetch deployments : %s", err)
+		os.Exit(1)
+	}
+	errs := manager.ResolveDeployments(deployments, manifests)
+	for _, err := range errs {
+		log.Warnf("error during analysis of deployment: %s", err)
+	}
+
+	statuses := boshupdate.Reconcile(deployments, manifests, generics)
+	findings := append(unavailable(deployments, manifests, generics, errs), evaluate(statuses, manifests, policy)...)
+
+	switch format {
+	case "junit":
+		printJUnit(statuses, findings)
+	case "sarif":
+		printSARIF(findings)
+	default:
+		for _, f := range findings {
+			fmt.Printf("%s\t%s\t%s: %s\n", f.Level, f.Deployment, f.Rule, f.Message)
+		}
+	}
+
+	os.Exit(exitCode(findings))
+}
+
+// Local Variables:
+// ispell-local-dictionary: "american"
+// End:
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/orange-cloudfoundry/boshupdate_exporter/boshupdate"
+)
+
+func TestCheckThreshold(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    int
+		warn     int
+		max      int
+		expected string
+	}{
+		{name: "below thresholds", value: 3, warn: 5, max: 10, expected: ""},
+		{name: "at warning threshold", value: 5, warn: 5, max: 10, expected: ""},
+		{name: "above warning threshold", value: 6, warn: 5, max: 10, expected: levelWarning},
+		{name: "above max threshold", value: 11, warn: 5, max: 10, expected: levelViolation},
+		{name: "disabled thresholds", value: 100, warn: 0, max: 0, expected: ""},
+		{name: "max only", value: 11, warn: 0, max: 10, expected: levelViolation},
+	}
+
+	for _, tt := range tests {
+		if res := checkThreshold(tt.value, tt.warn, tt.max); res != tt.expected {
+			t.Errorf("%s: expected '%s', got '%s'", tt.name, tt.expected, res)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	now := time.Now()
+	manifests := []boshupdate.ManifestReleaseData{
+		{
+			ManifestReleaseConfig: boshupdate.ManifestReleaseConfig{Matchers: []string{"^cf$"}},
+			Name:                  "cf",
+			Versions: []boshupdate.Version{
+				{Version: "3.0.0"},
+				{Version: "2.0.0"},
+				{Version: "1.0.0"},
+			},
+			LatestVersion: boshupdate.Version{Version: "3.0.0"},
+		},
+	}
+	status := func(ref string, outdatedDays int, releases ...boshupdate.BoshRelease) boshupdate.DeploymentStatus {
+		res := boshupdate.DeploymentStatus{
+			Deployment: "cf",
+			Current:    ref,
+			Latest:     "3.0.0",
+			Data: boshupdate.BoshDeploymentData{
+				Deployment:   "cf",
+				ManifestName: "cf",
+				Ref:          ref,
+				BoshReleases: releases,
+			},
+		}
+		if outdatedDays != 0 {
+			res.OutdatedSince = now.Add(-time.Duration(outdatedDays) * 24 * time.Hour).Unix()
+		}
+		return res
+	}
+
+	tests := []struct {
+		name     string
+		status   boshupdate.DeploymentStatus
+		policy   checkPolicy
+		expected []string
+	}{
+		{
+			name:     "up to date",
+			status:   status("3.0.0", 0),
+			policy:   checkPolicy{WarnDays: 1, MaxDays: 2, WarnVersions: 1, MaxVersions: 2},
+			expected: []string{},
+		},
+		{
+			name:     "outdated days warning",
+			status:   status("2.0.0", 10),
+			policy:   checkPolicy{WarnDays: 7, MaxDays: 30},
+			expected: []string{ruleDaysOutdated + "/" + levelWarning},
+		},
+		{
+			name:     "outdated days violation",
+			status:   status("2.0.0", 40),
+			policy:   checkPolicy{WarnDays: 7, MaxDays: 30},
+			expected: []string{ruleDaysOutdated + "/" + levelViolation},
+		},
+		{
+			name:     "versions behind",
+			status:   status("1.0.0", 1),
+			policy:   checkPolicy{WarnVersions: 1, MaxVersions: 3},
+			expected: []string{ruleVersionsBehind + "/" + levelWarning},
+		},
+		{
+			name:     "forbidden release",
+			status:   status("3.0.0", 0, boshupdate.BoshRelease{Name: "capi", Version: "1.0"}, boshupdate.BoshRelease{Name: "diego", Version: "2.0"}),
+			policy:   checkPolicy{ForbiddenRels: []*regexp.Regexp{regexp.MustCompile(`^capi/1\.`)}},
+			expected: []string{ruleForbidden + "/" + levelViolation},
+		},
+	}
+
+	for _, tt := range tests {
+		res := []string{}
+		for _, f := range evaluate([]boshupdate.DeploymentStatus{tt.status}, manifests, tt.policy) {
+			res = append(res, f.Rule+"/"+f.Level)
+		}
+		if !reflect.DeepEqual(res, tt.expected) {
+			t.Errorf("%s: expected findings %v, got %v", tt.name, tt.expected, res)
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	warning := finding{Level: levelWarning}
+	violation := finding{Level: levelViolation}
+
+	tests := []struct {
+		name     string
+		findings []finding
+		expected int
+	}{
+		{name: "no finding", findings: []finding{}, expected: 0},
+		{name: "warnings only", findings: []finding{warning, warning}, expected: exitWarning},
+		{name: "violation after warning", findings: []finding{warning, violation}, expected: exitViolation},
+		{name: "warning after violation", findings: []finding{violation, warning}, expected: exitViolation},
+	}
+
+	for _, tt := range tests {
+		if res := exitCode(tt.findings); res != tt.expected {
+			t.Errorf("%s: expected exit code %d, got %d", tt.name, tt.expected, res)
+		}
+	}
+}
+
+func TestJUnitSuite(t *testing.T) {
+	statuses := []boshupdate.DeploymentStatus{{Deployment: "cf"}, {Deployment: "redis"}}
+	findings := []finding{
+		{Deployment: "cf", Rule: ruleForbidden, Level: levelViolation, Message: "first"},
+		{Deployment: "cf", Rule: ruleForbidden, Level: levelViolation, Message: "second"},
+		{Deployment: "cf", Rule: ruleDaysOutdated, Level: levelWarning, Message: "old"},
+		{Deployment: "redis", Rule: ruleVersionsBehind, Level: levelViolation, Message: "behind"},
+		{Deployment: "manifest/cf", Rule: ruleUnavailable, Level: levelViolation, Message: "unreadable"},
+	}
+
+	suite := junitSuite(statuses, findings)
+	if suite.Tests != 9 {
+		t.Errorf("expected 9 test cases, got %d", suite.Tests)
+	}
+	if suite.Failures != 3 {
+		t.Errorf("expected 3 failures, got %d", suite.Failures)
+	}
+
+	cases := map[string]junitTestCase{}
+	for _, tc := range suite.TestCases {
+		cases[tc.ClassName+"/"+tc.Name] = tc
+	}
+	if tc := cases["cf/"+ruleForbidden]; tc.Failure == nil || tc.Failure.Message != "first; second" {
+		t.Errorf("expected failures of same rule to be aggregated, got %+v", tc.Failure)
+	}
+	if tc := cases["cf/"+ruleDaysOutdated]; tc.Failure != nil || tc.SystemOut != "warning: old\n" {
+		t.Errorf("expected warning to be reported as output, got %+v", tc)
+	}
+	if tc := cases["redis/"+ruleForbidden]; tc.Failure != nil || tc.SystemOut != "" {
+		t.Errorf("expected passing test case, got %+v", tc)
+	}
+	if tc := cases["manifest/cf/"+ruleUnavailable]; tc.Failure == nil {
+		t.Errorf("expected failing test case for unavailable release, got %+v", tc)
+	}
+	if _, found := cases["manifest/cf/"+ruleForbidden]; found {
+		t.Errorf("expected only %s test case for unavailable release", ruleUnavailable)
+	}
+}
+
+func TestUnavailable(t *testing.T) {
+	tests := []struct {
+		name        string
+		deployments []boshupdate.BoshDeploymentData
+		manifests   []boshupdate.ManifestReleaseData
+		generics    []boshupdate.GenericReleaseData
+		errs        []error
+		expected    []string
+	}{
+		{
+			name:        "all data read",
+			deployments: []boshupdate.BoshDeploymentData{{Deployment: "cf"}},
+			manifests:   []boshupdate.ManifestReleaseData{{Name: "cf"}},
+			generics:    []boshupdate.GenericReleaseData{{Name: "haproxy"}},
+			expected:    []string{},
+		},
+		{
+			name:        "deployment in error",
+			deployments: []boshupdate.BoshDeploymentData{{Deployment: "cf", HasError: true}, {Deployment: "redis"}},
+			expected:    []string{"cf: unable to read deployment from director"},
+		},
+		{
+			name:        "resolution error reported once",
+			deployments: []boshupdate.BoshDeploymentData{{Deployment: "cf", HasError: true}},
+			errs:        []error{&boshupdate.DeploymentError{Deployment: "cf", Message: "unable to detect version"}},
+			expected:    []string{"cf: unable to detect version"},
+		},
+		{
+			name:      "releases in error",
+			manifests: []boshupdate.ManifestReleaseData{{Name: "cf", HasError: true}},
+			generics:  []boshupdate.GenericReleaseData{{Name: "haproxy", HasError: true}},
+			expected: []string{
+				"manifest/cf: unable to read manifest release 'cf' from GitHub",
+				"generic/haproxy: unable to read generic release 'haproxy' from GitHub",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		res := []string{}
+		findings := unavailable(tt.deployments, tt.manifests, tt.generics, tt.errs)
+		for _, f := range findings {
+			if f.Rule != ruleUnavailable || f.Level != levelViolation {
+				t.Errorf("%s: unexpected finding %+v", tt.name, f)
+			}
+			res = append(res, f.Deployment+": "+f.Message)
+		}
+		if !reflect.DeepEqual(res, tt.expected) {
+			t.Errorf("%s: expected findings %v, got %v", tt.name, tt.expected, res)
+		}
+		if len(findings) != 0 && exitCode(findings) != exitViolation {
+			t.Errorf("%s: expected violation exit code", tt.name)
+		}
+	}
+}
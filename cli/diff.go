@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/orange-cloudfoundry/boshupdate_exporter/boshupdate"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// findManifestRelease - Gives manifest release of given name, exits when missing or in error
+func findManifestRelease(manager *boshupdate.Manager, name string) boshupdate.ManifestReleaseData {
+	for _, m := range manager.GetManifestReleasesFiltered(filter) {
+		if m.Name != name {
+			continue
+		}
+		if m.HasError {
+			log.Errorf("error during analysis of manifest release '%s'", name)
+			os.Exit(1)
+		}
+		return m
+	}
+	log.Errorf("unable to find manifest release '%s'", name)
+	os.Exit(1)
+	return boshupdate.ManifestReleaseData{}
+}
+
+func printDiff(diff *boshupdate.ManifestDiff, output string) {
+	switch output {
+	case "json":
+		content, _ := json.MarshalIndent(diff, "", "  ")
+		fmt.Println(string(content))
+
+	case "yaml":
+		content, _ := yaml.Marshal(diff)
+		fmt.Println(string(content))
+
+	default:
+		fmt.Printf("%s: %s -> %s\n\n", diff.Name, diff.From, diff.To)
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "CHANGE\tNAME\tFROM\tTO\tNOTES")
+		for _, br := range diff.Added {
+			_, _ = fmt.Fprintf(w, "added\t%s\t\t%s\t\n", br.Name, br.Version)
+		}
+		for _, br := range diff.Removed {
+			_, _ = fmt.Fprintf(w, "removed\t%s\t%s\t\t\n", br.Name, br.Version)
+		}
+		for _, b := range diff.Bumped {
+			_, _ = fmt.Fprintf(w, "bumped\t%s\t%s\t%s\t%s\n", b.Name, b.From, b.To, b.Notes)
+		}
+		for _, s := range diff.Stemcells {
+			_, _ = fmt.Fprintf(w, "stemcell\t%s\t%s\t%s\t\n", s.Alias, s.From, s.To)
+		}
+		_ = w.Flush()
+	}
+}
+
+func diff(manager *boshupdate.Manager, name string, from string, to string, output string) {
+	item := findManifestRelease(manager, name)
+	res, err := manager.DiffManifest(item, from, to)
+	if err != nil {
+		log.Errorf("unable to compare versions of manifest release '%s' : %s", name, err)
+		os.Exit(1)
+	}
+	printDiff(res, output)
+}
+
+// Local Variables:
+// ispell-local-dictionary: "american"
+// End:
@@ -8,6 +8,10 @@ import (
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
 	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
 )
 
 var (
@@ -21,12 +25,263 @@ var (
 	logJson = kingpin.Flag(
 		"log.json", "When given, write log in json format",
 	).Bool()
+	fromSnapshot = kingpin.Flag(
+		"from-snapshot", "Read data from given snapshot file instead of GitHub and bosh director",
+	).String()
+	manifestFilter = kingpin.Flag(
+		"manifest", "Only process manifest releases whose name or owner/repo matches given regexp",
+	).Regexp()
+	genericFilter = kingpin.Flag(
+		"generic", "Only process generic releases whose name or owner/repo matches given regexp",
+	).Regexp()
+	deploymentFilter = kingpin.Flag(
+		"deployment", "Only process bosh deployments whose name matches given regexp",
+	).Regexp()
+
+	// releases and deployments selected by command line
+	filter boshupdate.Filter
+
+	dumpCmd = kingpin.Command("dump", "Dump fetched manifest releases, generic releases and deployments").Default()
+
+	inferOpsCmd        = kingpin.Command("infer-ops", "Infer upstream ops-files that explain bosh releases of a deployment")
+	inferOpsDeployment = inferOpsCmd.Arg("deployment", "Name of the bosh deployment").Required().String()
+
+	cleanUpCmd = kingpin.Command("cleanup-report", "Report releases and stemcells uploaded to the director but unused")
+
+	reportCmd    = kingpin.Command("report", "Report current and latest versions of deployments with their outdated bosh releases")
+	reportOutput = reportCmd.Flag("output", "Output format").Default("table").Enum("table", "json", "yaml", "markdown")
+	reportMaxAge = reportCmd.Flag("max-age", "Exit with status 2 when a deployment or bosh release is outdated for longer, ie: 720h").Duration()
+
+	diffCmd      = kingpin.Command("diff", "Show bosh release and stemcell changes between two versions of a manifest release")
+	diffManifest = diffCmd.Arg("manifest-name", "Name of the manifest release").Required().String()
+	diffFrom     = diffCmd.Arg("from", "Version to upgrade from").Required().String()
+	diffTo       = diffCmd.Arg("to", "Version to upgrade to").Required().String()
+	diffOutput   = diffCmd.Flag("output", "Output format").Default("table").Enum("table", "json", "yaml")
+
+	planCmd        = kingpin.Command("plan", "Compute upgrade path of a deployment up to the latest manifest version")
+	planDeployment = planCmd.Arg("deployment", "Name of the bosh deployment").Required().String()
+	planOutput     = planCmd.Flag("output", "Output format").Default("table").Enum("table", "json", "yaml")
+
+	changelogCmd        = kingpin.Command("changelog", "Show release notes between current and latest version of a deployment")
+	changelogDeployment = changelogCmd.Arg("deployment", "Name of the bosh deployment").Required().String()
+	changelogOutput     = changelogCmd.Flag("output", "Output format").Default("text").Enum("text", "json", "yaml")
+
+	checkCmd          = kingpin.Command("check", "Check deployments against upgrade policy, exits with status 2 on violations and 3 on warnings")
+	checkWarnDays     = checkCmd.Flag("warn-days", "Warn when a deployment is outdated for more days").Int()
+	checkMaxDays      = checkCmd.Flag("max-days", "Fail when a deployment is outdated for more days").Int()
+	checkWarnVersions = checkCmd.Flag("warn-versions", "Warn when a deployment is more versions behind latest").Int()
+	checkMaxVersions  = checkCmd.Flag("max-versions", "Fail when a deployment is more versions behind latest").Int()
+	checkForbidden    = checkCmd.Flag("forbidden-release", "Fail when a bosh release '<name>/<version>' matches regexp, may be repeated").Strings()
+	checkFormat       = checkCmd.Flag("format", "Output format").Default("text").Enum("text", "junit", "sarif")
+
+	snapshotCmd  = kingpin.Command("snapshot", "Save fetched releases, deployments and their manifests to a file")
+	snapshotFile = snapshotCmd.Arg("file", "Path of the snapshot file").Required().String()
+
+	upgradeOpsCmd        = kingpin.Command("upgrade-ops", "Generate ops-file upgrading outdated bosh releases of a deployment to their latest version")
+	upgradeOpsDeployment = upgradeOpsCmd.Arg("deployment", "Name of the bosh deployment").Required().String()
+
+	validateCmd     = kingpin.Command("validate", "Validate configuration and check that director and GitHub sources are reachable")
+	validateOffline = validateCmd.Flag("offline", "Only check configuration, without querying director nor GitHub").Bool()
 )
 
+func dump(manager *boshupdate.Manager) {
+	var content []byte
+
+	manifests := manager.GetManifestReleasesFiltered(filter)
+	content, _ = yaml.Marshal(manifests)
+	fmt.Println("fetched manifest releases:")
+	fmt.Println(string(content))
+
+	generic := manager.GetGenericReleasesFiltered(filter)
+	content, _ = yaml.Marshal(generic)
+	fmt.Println("fetched generic releases:")
+	fmt.Println(string(content))
+
+	deployments, err := manager.GetBoshDeploymentsFiltered(filter)
+	if err != nil {
+		log.Errorf("unable to fetch deployments : %s", err)
+		os.Exit(1)
+	}
+	content, _ = yaml.Marshal(deployments)
+	fmt.Println("fetched deployments:")
+	fmt.Println(string(content))
+}
+
+// findDeployment - Gives deployment of given name with its detected version, exits when
+// missing or in error
+func findDeployment(manager *boshupdate.Manager, name string, manifests []boshupdate.ManifestReleaseData) *boshupdate.BoshDeploymentData {
+	deployments, err := manager.GetBoshDeploymentsFiltered(filter)
+	if err != nil {
+		log.Errorf("unable to fetch deployments : %s", err)
+		os.Exit(1)
+	}
+
+	var deployment *boshupdate.BoshDeploymentData
+	for idx := range deployments {
+		if deployments[idx].Deployment == name && !deployments[idx].HasError {
+			deployment = &deployments[idx]
+		}
+	}
+	if deployment == nil {
+		log.Errorf("unable to find deployment '%s'", name)
+		os.Exit(1)
+	}
+
+	if deployment.Ref == "" {
+		if err := manager.DetectVersion(deployment, manifests); err != nil {
+			log.Errorf("%s", err)
+			os.Exit(1)
+		}
+	}
+	return deployment
+}
+
+func snapshot(manager *boshupdate.Manager, path string) {
+	data, err := manager.TakeSnapshot(filter)
+	if err != nil {
+		log.Errorf("unable to take snapshot : %s", err)
+		os.Exit(1)
+	}
+	if err = data.Write(path); err != nil {
+		log.Errorf("%s", err)
+		os.Exit(1)
+	}
+}
+
+// upgradeOps - Prints ops-file of given deployment, deployments without manifest version
+// are accepted as long as their bosh releases are known
+func upgradeOps(manager *boshupdate.Manager, name string) {
+	manifests := manager.GetManifestReleasesFiltered(filter)
+	generics := manager.GetGenericReleasesFiltered(filter)
+	deployments, err := manager.GetBoshDeploymentsFiltered(filter)
+	if err != nil {
+		log.Errorf("unable to fetch deployments : %s", err)
+		os.Exit(1)
+	}
+
+	for idx := range deployments {
+		d := deployments[idx : idx+1]
+		if d[0].Deployment != name || len(d[0].BoshReleases) == 0 {
+			continue
+		}
+		for _, err := range manager.ResolveDeployments(d, manifests) {
+			log.Warnf("%s", err)
+		}
+		ops, err := manager.UpgradeOps(d[0], manifests, generics)
+		if err != nil {
+			log.Errorf("unable to generate ops-file : %s", err)
+			os.Exit(1)
+		}
+		content, _ := yaml.Marshal(ops)
+		fmt.Print(string(content))
+		return
+	}
+
+	log.Errorf("unable to find deployment '%s'", name)
+	os.Exit(1)
+}
+
+// validate - Reports all configuration issues, exits with status 1 when any
+//
+// Sources are only queried when configuration is valid, manager can't be created otherwise.
+func validate(offline bool) {
+	config, err := boshupdate.ParseConfig(*configFile)
+	if err != nil {
+		log.Errorf("%s", err)
+		os.Exit(1)
+	}
+
+	errs := config.ValidateAll()
+	if len(errs) == 0 && !offline {
+		manager, err := boshupdate.NewManager(*config)
+		if err != nil {
+			log.Errorf("unable to create manager : %s", err)
+			os.Exit(1)
+		}
+		errs = manager.ValidateSources()
+	}
+
+	if len(errs) == 0 {
+		fmt.Println("configuration is valid")
+		return
+	}
+	fmt.Printf("found %d configuration issues:\n", len(errs))
+	for _, err := range errs {
+		fmt.Printf("- %s\n", err)
+	}
+	os.Exit(1)
+}
+
+func inferOps(manager *boshupdate.Manager, name string) {
+	manifests := manager.GetManifestReleasesFiltered(filter)
+	deployment := findDeployment(manager, name, manifests)
+
+	for _, m := range manifests {
+		if m.HasError || !m.Match(deployment.ManifestName) {
+			continue
+		}
+		analysis, err := manager.InferOps(*deployment, m)
+		if err != nil {
+			log.Errorf("unable to infer ops-files : %s", err)
+			os.Exit(1)
+		}
+		content, _ := yaml.Marshal(analysis)
+		fmt.Println(string(content))
+		return
+	}
+
+	log.Errorf("unable to find manifest release matching deployment '%s'", name)
+	os.Exit(1)
+}
+
+func cleanUpReport(manager *boshupdate.Manager) {
+	assets, err := manager.GetAssets()
+	if err != nil {
+		log.Errorf("unable to fetch director assets : %s", err)
+		os.Exit(1)
+	}
+
+	counts := map[string]int{}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "TYPE\tNAME\tVERSION\tSTATUS\tDETAILS")
+	for _, a := range assets {
+		name := a.Name
+		if a.OS != "" {
+			name = fmt.Sprintf("%s (%s)", a.Name, a.OS)
+		}
+		status := "used"
+		details := strings.Join(a.Deployments, ", ")
+		switch {
+		case a.IsUsed():
+			counts[a.Type+" used"]++
+		case a.CleanUp:
+			status = "clean-up"
+			details = "deleted by 'bosh clean-up'"
+			counts[a.Type+" deleted by clean-up"]++
+		default:
+			status = "clean-up --all"
+			details = "kept as recent version, deleted by 'bosh clean-up --all'"
+			counts[a.Type+" deleted by clean-up --all"]++
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", a.Type, name, a.Version, status, details)
+	}
+	_ = w.Flush()
+
+	fmt.Println()
+	keys := []string{}
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf("%s: %d\n", k, counts[k])
+	}
+}
+
 func main() {
 	kingpin.Version(version.Print("boshupdate_cli"))
 	kingpin.HelpFlag.Short('h')
-	kingpin.Parse()
+	command := kingpin.Parse()
 
 	log.SetLevel(log.ErrorLevel)
 	if lvl, err := log.ParseLevel(*logLevel); err == nil {
@@ -39,31 +294,74 @@ func main() {
 	if *logJson {
 		log.SetFormatter(&log.JSONFormatter{})
 	}
-	config := boshupdate.NewConfig(*configFile)
+	// configuration issues are reported instead of being fatal
+	if command == validateCmd.FullCommand() {
+		validate(*validateOffline)
+		return
+	}
+
+	var config *boshupdate.Config
+	if *fromSnapshot != "" {
+		// snapshot may be read on another machine, lacking credential files
+		config = boshupdate.NewOfflineConfig(*configFile)
+	} else {
+		config = boshupdate.NewConfig(*configFile)
+	}
+	filter = boshupdate.Filter{
+		Manifest:   *manifestFilter,
+		Generic:    *genericFilter,
+		Deployment: *deploymentFilter,
+	}
 
-	var content []byte
 	manager, err := boshupdate.NewManager(*config)
 	if err != nil {
 		log.Errorf("unable to start exporter : %s", err)
 		os.Exit(1)
 	}
 
-	manifests := manager.GetManifestReleases()
-	content, _ = yaml.Marshal(manifests)
-	fmt.Println("fetched manifest releases:")
-	fmt.Println(string(content))
-
-	generic := manager.GetGenericReleases()
-	content, _ = yaml.Marshal(generic)
-	fmt.Println("fetched generic releases:")
-	fmt.Println(string(content))
+	if *fromSnapshot != "" {
+		data, err := boshupdate.LoadSnapshot(*fromSnapshot)
+		if err != nil {
+			log.Errorf("%s", err)
+			os.Exit(1)
+		}
+		manager.UseSnapshot(data)
+	}
 
-	deployments, err := manager.GetBoshDeployments()
-	if err != nil {
-		log.Errorf("unable to fetch deployments : %s", err)
-		os.Exit(1)
+	switch command {
+	case dumpCmd.FullCommand():
+		dump(manager)
+	case inferOpsCmd.FullCommand():
+		inferOps(manager, *inferOpsDeployment)
+	case cleanUpCmd.FullCommand():
+		cleanUpReport(manager)
+	case reportCmd.FullCommand():
+		report(manager, *reportOutput, *reportMaxAge)
+	case diffCmd.FullCommand():
+		diff(manager, *diffManifest, *diffFrom, *diffTo, *diffOutput)
+	case planCmd.FullCommand():
+		plan(manager, *planDeployment, *planOutput)
+	case changelogCmd.FullCommand():
+		changelog(manager, *changelogDeployment, *changelogOutput)
+	case checkCmd.FullCommand():
+		policy := checkPolicy{
+			WarnDays:     *checkWarnDays,
+			MaxDays:      *checkMaxDays,
+			WarnVersions: *checkWarnVersions,
+			MaxVersions:  *checkMaxVersions,
+		}
+		for _, f := range *checkForbidden {
+			re, err := regexp.Compile(f)
+			if err != nil {
+				log.Errorf("invalid forbidden release regexp '%s'", f)
+				os.Exit(1)
+			}
+			policy.ForbiddenRels = append(policy.ForbiddenRels, re)
+		}
+		check(manager, policy, *checkFormat)
+	case snapshotCmd.FullCommand():
+		snapshot(manager, *snapshotFile)
+	case upgradeOpsCmd.FullCommand():
+		upgradeOps(manager, *upgradeOpsDeployment)
 	}
-	content, _ = yaml.Marshal(deployments)
-	fmt.Println("fetched deployments:")
-	fmt.Println(string(content))
 }
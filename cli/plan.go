@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/orange-cloudfoundry/boshupdate_exporter/boshupdate"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+func printPlan(plan *boshupdate.UpgradePlan, output string) {
+	switch output {
+	case "json":
+		content, _ := json.MarshalIndent(plan, "", "  ")
+		fmt.Println(string(content))
+
+	case "yaml":
+		content, _ := yaml.Marshal(plan)
+		fmt.Println(string(content))
+
+	default:
+		fmt.Printf("%s (%s): %s -> %s\n", plan.Deployment, plan.ManifestName, plan.From, plan.To)
+		if len(plan.Hops) == 0 {
+			fmt.Println("\nalready up to date")
+		}
+		for idx, hop := range plan.Hops {
+			flags := []string{}
+			if hop.Required {
+				flags = append(flags, "required stop")
+			}
+			if len(hop.Breaking) != 0 {
+				flags = append(flags, "breaking changes in "+strings.Join(hop.Breaking, ", "))
+			}
+			fmt.Printf("\nstep %d: %s -> %s", idx+1, hop.From, hop.To)
+			if len(flags) != 0 {
+				fmt.Printf(" [%s]", strings.Join(flags, ", "))
+			}
+			fmt.Println()
+			for _, br := range hop.Changes.Added {
+				fmt.Printf("  + %s %s\n", br.Name, br.Version)
+			}
+			for _, br := range hop.Changes.Removed {
+				fmt.Printf("  - %s %s\n", br.Name, br.Version)
+			}
+			for _, b := range hop.Changes.Bumped {
+				fmt.Printf("  ~ %s %s -> %s\n", b.Name, b.From, b.To)
+			}
+			for _, s := range hop.Changes.Stemcells {
+				fmt.Printf("  ~ stemcell %s %s -> %s\n", s.Alias, s.From, s.To)
+			}
+		}
+	}
+}
+
+func plan(manager *boshupdate.Manager, name string, output string) {
+	manifests := manager.GetManifestReleasesFiltered(filter)
+	deployment := findDeployment(manager, name, manifests)
+
+	manifest, _ := boshupdate.FindVersion(*deployment, manifests)
+	if manifest == nil {
+		log.Errorf("unable to find manifest release matching deployment '%s' at version '%s'", name, deployment.Ref)
+		os.Exit(1)
+	}
+
+	res, err := manager.PlanUpgrade(*deployment, *manifest)
+	if err != nil {
+		log.Errorf("unable to plan upgrade of deployment '%s' : %s", name, err)
+		os.Exit(1)
+	}
+	printPlan(res, output)
+}
+
+// Local Variables:
+// ispell-local-dictionary: "american"
+// End:
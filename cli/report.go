@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/orange-cloudfoundry/boshupdate_exporter/boshupdate"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// buildReport - Reconciles deployments with manifest and generic releases, keeping only
+// outdated bosh releases
+func buildReport(manager *boshupdate.Manager) []boshupdate.DeploymentStatus {
+	manifests := manager.GetManifestReleasesFiltered(filter)
+	generics := manager.GetGenericReleasesFiltered(filter)
+	deployments, err := manager.GetBoshDeploymentsFiltered(filter)
+	if err != nil {
+		log.Errorf("unable to fetch deployments : %s", err)
+		os.Exit(1)
+	}
+
+	for _, d := range deployments {
+		if d.HasError {
+			log.Warnf("error during analysis of deployment '%s'", d.Deployment)
+		}
+	}
+	for _, err := range manager.ResolveDeployments(deployments, manifests) {
+		log.Warnf("error during analysis of deployment: %s", err)
+	}
+
+	res := boshupdate.Reconcile(deployments, manifests, generics)
+	for idx := range res {
+		outdated := []boshupdate.ReleaseStatus{}
+		for _, rs := range res[idx].Releases {
+			if rs.IsOutdated() {
+				outdated = append(outdated, rs)
+			}
+		}
+		res[idx].Releases = outdated
+	}
+	return res
+}
+
+// formatAge - Gives human readable duration since given epoch, '-' when up to date
+func formatAge(since int64) string {
+	if since == 0 {
+		return "-"
+	}
+	age := time.Since(time.Unix(since, 0))
+	if age < 24*time.Hour {
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(age.Hours()/24))
+}
+
+// exceeds - Tells if epoch is older than given maximum age, zero age disables check
+func exceeds(since int64, maxAge time.Duration) bool {
+	return maxAge > 0 && since != 0 && time.Since(time.Unix(since, 0)) > maxAge
+}
+
+func printReport(reports []boshupdate.DeploymentStatus, output string) {
+	switch output {
+	case "json":
+		content, _ := json.MarshalIndent(reports, "", "  ")
+		fmt.Println(string(content))
+
+	case "yaml":
+		content, _ := yaml.Marshal(reports)
+		fmt.Println(string(content))
+
+	case "markdown":
+		fmt.Println("| Deployment | Manifest | Current | Latest | Age |")
+		fmt.Println("|------------|----------|---------|--------|-----|")
+		for _, r := range reports {
+			fmt.Printf("| %s | %s | %s | %s | %s |\n", r.Deployment, r.ManifestName, r.Current, r.Latest, formatAge(r.OutdatedSince))
+			for _, br := range r.Releases {
+				fmt.Printf("| &nbsp;&nbsp;↳ %s | | %s | %s | %s |\n", br.Name, br.Current, br.Latest, formatAge(br.OutdatedSince))
+			}
+		}
+
+	default:
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "DEPLOYMENT\tMANIFEST\tCURRENT\tLATEST\tAGE")
+		for _, r := range reports {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Deployment, r.ManifestName, r.Current, r.Latest, formatAge(r.OutdatedSince))
+			for _, br := range r.Releases {
+				_, _ = fmt.Fprintf(w, "  - %s\t\t%s\t%s\t%s\n", br.Name, br.Current, br.Latest, formatAge(br.OutdatedSince))
+			}
+		}
+		_ = w.Flush()
+	}
+}
+
+// report - Prints reconciled upgrade view, exits with status 2 when something is
+// outdated for longer than maxAge
+func report(manager *boshupdate.Manager, output string, maxAge time.Duration) {
+	reports := buildReport(manager)
+	printReport(reports, output)
+
+	outdated := []string{}
+	for _, r := range reports {
+		if exceeds(r.OutdatedSince, maxAge) {
+			outdated = append(outdated, r.Deployment)
+			continue
+		}
+		for _, br := range r.Releases {
+			if exceeds(br.OutdatedSince, maxAge) {
+				outdated = append(outdated, r.Deployment)
+				break
+			}
+		}
+	}
+	if len(outdated) != 0 {
+		log.Errorf("deployments outdated for more than %s: %s", maxAge, strings.Join(outdated, ", "))
+		os.Exit(2)
+	}
+}
+
+// Local Variables:
+// ispell-local-dictionary: "american"
+// End:
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/orange-cloudfoundry/boshupdate_exporter/boshupdate"
+	log "github.com/sirupsen/logrus"
+)
+
+// opsResult - Ops-files analysis of a deployment, or the error that prevented it
+type opsResult struct {
+	analysis *boshupdate.OpsAnalysis
+	err      error
+}
+
+// state - Data collected during last update, served by the API
+type state struct {
+	mutex       sync.RWMutex
+	manifests   []boshupdate.ManifestReleaseData
+	deployments []boshupdate.BoshDeploymentData
+	ops         map[string]opsResult
+}
+
+var lastState state
+
+func (s *state) set(manifests []boshupdate.ManifestReleaseData, deployments []boshupdate.BoshDeploymentData, ops map[string]opsResult) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.manifests = manifests
+	s.deployments = deployments
+	s.ops = ops
+}
+
+// findOps - Gives ops-files analysis of given deployment computed during last update
+func (s *state) findOps(name string) (opsResult, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	res, found := s.ops[name]
+	return res, found
+}
+
+// find - Gives deployment of given name and its matching manifest release, if any
+func (s *state) find(name string) (*boshupdate.BoshDeploymentData, *boshupdate.ManifestReleaseData) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, d := range s.deployments {
+		if d.Deployment != name || d.HasError {
+			continue
+		}
+		manifest, _ := boshupdate.FindVersion(d, s.manifests)
+		return &d, manifest
+	}
+	return nil, nil
+}
+
+// inferOps - Analyzes ops-files of deployments whose manifest release gives an ops_dir
+//
+// The greedy search is expensive, it is run by the update loop only and its results
+// are served by the API.
+func inferOps(manager *boshupdate.Manager, deployments []boshupdate.BoshDeploymentData, manifests []boshupdate.ManifestReleaseData) map[string]opsResult {
+	res := map[string]opsResult{}
+	for _, d := range deployments {
+		if d.HasError {
+			continue
+		}
+		manifest, _ := boshupdate.FindVersion(d, manifests)
+		if manifest == nil || len(manifest.OpsDir) == 0 || len(manifest.Manifest) == 0 {
+			continue
+		}
+		analysis, err := manager.InferOps(d, *manifest)
+		res[d.Deployment] = opsResult{analysis: analysis, err: err}
+	}
+	return res
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Errorf("write error: %s", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+func apiHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/v1/deployments/{deployment}/ops", func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("deployment")
+		deployment, manifest := lastState.find(name)
+		if deployment == nil {
+			writeError(w, http.StatusNotFound, "unknown deployment '"+name+"'")
+			return
+		}
+		if manifest == nil {
+			writeError(w, http.StatusNotFound, "no manifest release matches deployment '"+name+"'")
+			return
+		}
+		result, found := lastState.findOps(name)
+		if !found {
+			writeError(w, http.StatusNotFound, "no ops_dir configured for manifest release '"+manifest.Name+"'")
+			return
+		}
+		if result.err != nil {
+			writeError(w, http.StatusInternalServerError, result.err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, result.analysis)
+	})
+
+	mux.HandleFunc("GET /api/v1/deployments/{deployment}/changelog", func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("deployment")
+		deployment, manifest := lastState.find(name)
+		if deployment == nil {
+			writeError(w, http.StatusNotFound, "unknown deployment '"+name+"'")
+			return
+		}
+		if manifest == nil {
+			writeError(w, http.StatusNotFound, "no manifest release matches deployment '"+name+"'")
+			return
+		}
+		writeJSON(w, http.StatusOK, boshupdate.Changelog(*deployment, *manifest))
+	})
+
+	return withAuth(mux)
+}
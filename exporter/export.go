@@ -5,21 +5,36 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
 	"time"
 )
 
 var (
 	manifestRelease                 *prometheus.GaugeVec
+	manifestReleaseSecurityFix      *prometheus.GaugeVec
 	manifestBoshRelease             *prometheus.GaugeVec
 	deploymentStatus                *prometheus.GaugeVec
+	deploymentConfidence            *prometheus.GaugeVec
 	deploymentReleaseStatus         *prometheus.GaugeVec
 	genericRelease                  *prometheus.GaugeVec
+	genericReleaseSecurityFix       *prometheus.GaugeVec
+	configReleaseStatus             *prometheus.GaugeVec
+	directorInfo                    *prometheus.GaugeVec
+	directorStatus                  *prometheus.GaugeVec
+	directorAssets                  *prometheus.GaugeVec
+	directorUnusedAsset             *prometheus.GaugeVec
+	instanceGroupStemcell           *prometheus.GaugeVec
+	instanceGroupRelease            *prometheus.GaugeVec
+	deploymentDrift                 *prometheus.GaugeVec
+	manifestCacheRequests           *prometheus.CounterVec
+	directorUp                      prometheus.Gauge
 	lastScrapeTimestampMetric       prometheus.Gauge
 	lastScrapeErrorMetric           prometheus.Gauge
 	lastScrapeDurationSecondsMetric prometheus.Gauge
 )
 
-func initMetricsReporter(namespace string, environment string) {
+func initMetricsReporter(namespace string, environment string, extraLabels []string) {
 	manifestRelease = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace:   namespace,
@@ -31,6 +46,17 @@ func initMetricsReporter(namespace string, environment string) {
 		[]string{"name", "version", "owner", "repo"},
 	)
 
+	manifestReleaseSecurityFix = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "",
+			Name:        "manifest_release_security_fix",
+			Help:        "Tells if release notes of manifest version mention security or a CVE, (1 for true, only for versions published as github releases)",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+		[]string{"name", "version"},
+	)
+
 	manifestBoshRelease = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace:   namespace,
@@ -53,6 +79,17 @@ func initMetricsReporter(namespace string, environment string) {
 		[]string{"name", "version", "owner", "repo"},
 	)
 
+	genericReleaseSecurityFix = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "",
+			Name:        "generic_release_security_fix",
+			Help:        "Tells if release notes of github release version mention security or a CVE, (1 for true, only for versions published as github releases)",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+		[]string{"name", "version"},
+	)
+
 	deploymentStatus = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace:   namespace,
@@ -61,7 +98,18 @@ func initMetricsReporter(namespace string, environment string) {
 			Help:        "Seconds from epoch since this deployment is out of date, (0 means up to date)",
 			ConstLabels: prometheus.Labels{"environment": environment},
 		},
-		[]string{"deployment", "name", "current", "latest"},
+		append([]string{"deployment", "name", "current", "latest", "detection"}, extraLabels...),
+	)
+
+	deploymentConfidence = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "",
+			Name:        "deployment_detection_confidence",
+			Help:        "Similarity between deployed bosh releases and those of the detected version, (1 means identical)",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+		[]string{"deployment", "name", "detection"},
 	)
 
 	deploymentReleaseStatus = promauto.NewGaugeVec(
@@ -72,7 +120,116 @@ func initMetricsReporter(namespace string, environment string) {
 			Help:        "Seconds from epoch since this bosh release is out of date, (0 means up to date)",
 			ConstLabels: prometheus.Labels{"environment": environment},
 		},
-		[]string{"deployment", "manifest_name", "manifest_current", "manifest_latest", "boshrelease_name", "boshrelease_current", "boshrelease_latest"},
+		append([]string{"deployment", "manifest_name", "manifest_current", "manifest_latest", "boshrelease_name", "boshrelease_current", "boshrelease_latest"}, extraLabels...),
+	)
+
+	configReleaseStatus = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "",
+			Name:        "runtime_config_release_status",
+			Help:        "Seconds from epoch since this runtime or cloud config bosh release is out of date, (0 means up to date)",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+		[]string{"config", "type", "boshrelease_name", "boshrelease_current", "boshrelease_latest"},
+	)
+
+	directorInfo = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "",
+			Name:        "director_info",
+			Help:        "Informational metric that gives the bosh director version, cpi and stemcell, (always 0)",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+		[]string{"name", "uuid", "version", "cpi", "stemcell_os", "stemcell_version"},
+	)
+
+	directorStatus = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "",
+			Name:        "director_status",
+			Help:        "Seconds from epoch since the bosh director is out of date, (0 means up to date)",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+		[]string{"name", "source", "current", "latest"},
+	)
+
+	directorAssets = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "",
+			Name:        "director_assets",
+			Help:        "Number of releases or stemcells uploaded to the director",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+		[]string{"type", "state"},
+	)
+
+	directorUnusedAsset = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "",
+			Name:        "director_unused_asset",
+			Help:        "Seconds from epoch since this release or stemcell is known to be unused by any deployment",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+		[]string{"type", "name", "os", "version", "clean_up"},
+	)
+
+	instanceGroupStemcell = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "",
+			Name:        "instance_group_stemcell",
+			Help:        "Number of instances of an instance group running given stemcell, (only in deep mode)",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+		[]string{"deployment", "instance_group", "stemcell_name", "stemcell_version", "expected_version"},
+	)
+
+	instanceGroupRelease = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "",
+			Name:        "instance_group_bosh_release_info",
+			Help:        "Informational metric that gives the bosh releases used by jobs of an instance group, (always 0, only in deep mode)",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+		[]string{"deployment", "instance_group", "boshrelease_name", "boshrelease_version"},
+	)
+
+	deploymentDrift = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "",
+			Name:        "deployment_drift",
+			Help:        "Number of differences between running state of deployment and its manifest, (only in deep mode)",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+		[]string{"deployment"},
+	)
+
+	manifestCacheRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "",
+			Name:        "manifest_cache_requests_total",
+			Help:        "Number of deployment manifest lookups, by cache result (hit or miss)",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+		[]string{"result"},
+	)
+
+	directorUp = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "",
+			Name:        "director_up",
+			Help:        "Tells if last request to bosh director succeeded, (1 for success, 0 for failure)",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
 	)
 
 	lastScrapeTimestampMetric = promauto.NewGauge(
@@ -106,45 +263,17 @@ func initMetricsReporter(namespace string, environment string) {
 	)
 }
 
-// getVersion -
-// fetch deployment.Versions match manifest.Name
-func getVersion(
-	deployment boshupdate.BoshDeploymentData,
-	releases []boshupdate.ManifestReleaseData) (*boshupdate.ManifestReleaseData, *boshupdate.Version) {
-
-	for _, r := range releases {
-		if !r.Match(deployment.ManifestName) {
-			continue
-		}
-		for _, v := range r.Versions {
-			if v.Version == deployment.Ref {
-				return &r, &v
-			}
-		}
-	}
-	return nil, nil
-}
-
-func getBoshReleaseVersion(
-	manifest *boshupdate.ManifestReleaseData,
-	boshRelease boshupdate.BoshRelease) *boshupdate.BoshRelease {
-	for _, br := range manifest.BoshReleases {
-		if br.Name == boshRelease.Name {
-			return &br
-		}
-	}
-	return nil
-}
-
-func startUpdate(manager *boshupdate.Manager, interval time.Duration) {
+func startUpdate(manager *boshupdate.Manager, config boshupdate.BoshConfig, interval time.Duration, filter boshupdate.Filter) {
 	go func() {
+		var lastStats boshupdate.CacheStats
 		for {
 			log.Debugf("collecting boshupdate metrics")
 			startTime := time.Now()
 			lastScrapeErrorMetric.Set(0)
 
-			manifests := manager.GetManifestReleases()
+			manifests := manager.GetManifestReleasesFiltered(filter)
 			manifestRelease.Reset()
+			manifestReleaseSecurityFix.Reset()
 			manifestBoshRelease.Reset()
 			for _, m := range manifests {
 				if m.HasError {
@@ -156,6 +285,14 @@ func startUpdate(manager *boshupdate.Manager, interval time.Duration) {
 					manifestRelease.
 						WithLabelValues(m.Name, v.Version, m.Owner, m.Repo).
 						Set(float64(v.ExpiredSince))
+					// tags have no release notes, nothing is known about their fixes
+					if v.Notes != nil {
+						fix := 0.0
+						if v.Notes.HasSecurityFix() {
+							fix = 1
+						}
+						manifestReleaseSecurityFix.WithLabelValues(m.Name, v.Version).Set(fix)
+					}
 				}
 				for _, r := range m.BoshReleases {
 					manifestBoshRelease.
@@ -164,8 +301,9 @@ func startUpdate(manager *boshupdate.Manager, interval time.Duration) {
 				}
 			}
 
-			generics := manager.GetGenericReleases()
+			generics := manager.GetGenericReleasesFiltered(filter)
 			genericRelease.Reset()
+			genericReleaseSecurityFix.Reset()
 			for _, r := range generics {
 				if r.HasError {
 					log.Warnf("error during analysis of github release '%s'", r.Name)
@@ -176,12 +314,27 @@ func startUpdate(manager *boshupdate.Manager, interval time.Duration) {
 					genericRelease.
 						WithLabelValues(r.Name, v.Version, r.Owner, r.Repo).
 						Set(float64(v.ExpiredSince))
+					if v.Notes != nil {
+						fix := 0.0
+						if v.Notes.HasSecurityFix() {
+							fix = 1
+						}
+						genericReleaseSecurityFix.WithLabelValues(r.Name, v.Version).Set(fix)
+					}
 				}
 			}
 
-			deployments, err := manager.GetBoshDeployments()
+			deployments, err := manager.GetBoshDeploymentsFiltered(filter)
+			stats := manager.ManifestCacheStats()
+			manifestCacheRequests.WithLabelValues("hit").Add(float64(stats.Hits - lastStats.Hits))
+			manifestCacheRequests.WithLabelValues("miss").Add(float64(stats.Misses - lastStats.Misses))
+			lastStats = stats
 			deploymentStatus.Reset()
+			deploymentConfidence.Reset()
 			deploymentReleaseStatus.Reset()
+			instanceGroupStemcell.Reset()
+			instanceGroupRelease.Reset()
+			deploymentDrift.Reset()
 			if err != nil {
 				log.Errorf("unable to get bosh deployments: %s", err)
 				lastScrapeErrorMetric.Add(1.0)
@@ -191,37 +344,113 @@ func startUpdate(manager *boshupdate.Manager, interval time.Duration) {
 				if d.HasError {
 					lastScrapeErrorMetric.Add(1.0)
 					log.Warnf("error during analysis of deployment '%s'", d.Deployment)
-					continue
 				}
+			}
+			for _, err := range manager.ResolveDeployments(deployments, manifests) {
+				lastScrapeErrorMetric.Add(1.0)
+				log.Warnf("error during analysis of deployment: %s", err)
+			}
 
-				manifest, version := getVersion(d, manifests)
-				if manifest == nil || version == nil {
-					deploymentStatus.
-						WithLabelValues(d.Deployment, d.ManifestName, d.Ref, "not-found").
-						Set(0)
-				} else {
-					deploymentStatus.
-						WithLabelValues(d.Deployment, manifest.Name, version.Version, manifest.LatestVersion.Version).
-						Set(float64(version.ExpiredSince))
-					for _, br := range d.BoshReleases {
-						latestBr := getBoshReleaseVersion(manifest, br)
-						if latestBr == nil {
-							deploymentReleaseStatus.
-								WithLabelValues(d.Deployment, manifest.Name, version.Version, manifest.LatestVersion.Version, br.Name, br.Version, "not-found").
+			for _, status := range boshupdate.Reconcile(deployments, manifests, generics) {
+				d := status.Data
+				if config.Deep {
+					deploymentDrift.WithLabelValues(d.Deployment).Set(float64(len(d.Drifts)))
+					for _, ig := range d.InstanceGroups {
+						for stemcell, count := range ig.Stemcells {
+							name, version, _ := strings.Cut(stemcell, "/")
+							instanceGroupStemcell.
+								WithLabelValues(d.Deployment, ig.Name, name, version, ig.ExpectedStemcell).
+								Set(float64(count))
+						}
+						for _, br := range ig.Releases {
+							instanceGroupRelease.
+								WithLabelValues(d.Deployment, ig.Name, br.Name, br.Version).
 								Set(0)
-						} else {
-							value := version.ExpiredSince
-							if br.Version == latestBr.Version {
-								value = 0
-							}
-							deploymentReleaseStatus.
-								WithLabelValues(d.Deployment, manifest.Name, version.Version, manifest.LatestVersion.Version, br.Name, br.Version, latestBr.Version).
-								Set(float64(value))
 						}
 					}
 				}
+
+				extra := config.Labels.Values(d)
+				deploymentStatus.
+					WithLabelValues(append([]string{status.Deployment, status.ManifestName, status.Current, status.Latest, status.Detection}, extra...)...).
+					Set(float64(status.OutdatedSince))
+				deploymentConfidence.
+					WithLabelValues(status.Deployment, status.ManifestName, status.Detection).
+					Set(status.Confidence)
+				if status.Latest == boshupdate.NotFound {
+					continue
+				}
+				for _, rs := range status.Releases {
+					deploymentReleaseStatus.
+						WithLabelValues(append([]string{status.Deployment, status.ManifestName, status.Current, status.Latest, rs.Name, rs.Current, rs.Latest}, extra...)...).
+						Set(float64(rs.OutdatedSince))
+				}
+			}
+
+			configs, err := manager.GetConfigs()
+			configReleaseStatus.Reset()
+			if err != nil {
+				log.Errorf("unable to get director configs: %s", err)
+				lastScrapeErrorMetric.Add(1.0)
+			}
+
+			for _, c := range configs {
+				if c.HasError {
+					lastScrapeErrorMetric.Add(1.0)
+					log.Warnf("error during analysis of %s config '%s'", c.Type, c.Name)
+					continue
+				}
+				for _, rs := range boshupdate.ReconcileConfig(c, manifests, generics) {
+					configReleaseStatus.
+						WithLabelValues(c.Name, c.Type, rs.Name, rs.Current, rs.Latest).
+						Set(float64(rs.OutdatedSince))
+				}
 			}
 
+			director, err := manager.GetDirector()
+			directorInfo.Reset()
+			directorStatus.Reset()
+			if err != nil {
+				log.Errorf("unable to get director info: %s", err)
+				lastScrapeErrorMetric.Add(1.0)
+			} else {
+				directorInfo.
+					WithLabelValues(director.Name, director.UUID, director.Version, director.CPI, director.StemcellOS, director.StemcellVersion).
+					Set(0)
+				if config.Source != "" {
+					rs := boshupdate.ReconcileDirector(*director, config.Source, manifests, generics)
+					directorStatus.
+						WithLabelValues(director.Name, config.Source, rs.Current, rs.Latest).
+						Set(float64(rs.OutdatedSince))
+				}
+			}
+
+			assets, err := manager.GetAssets()
+			directorAssets.Reset()
+			directorUnusedAsset.Reset()
+			if err != nil {
+				log.Errorf("unable to get director assets: %s", err)
+				lastScrapeErrorMetric.Add(1.0)
+			}
+			for _, a := range assets {
+				if a.IsUsed() {
+					directorAssets.WithLabelValues(a.Type, "used").Add(1)
+					continue
+				}
+				directorAssets.WithLabelValues(a.Type, "unused").Add(1)
+				directorUnusedAsset.
+					WithLabelValues(a.Type, a.Name, a.OS, a.Version, strconv.FormatBool(a.CleanUp)).
+					Set(float64(a.UnusedSince))
+			}
+
+			if manager.IsDirectorUp() {
+				directorUp.Set(1)
+			} else {
+				directorUp.Set(0)
+			}
+
+			lastState.set(manifests, deployments, inferOps(manager, deployments, manifests))
+
 			duration := time.Since(startTime).Seconds()
 			lastScrapeTimestampMetric.Set(float64(time.Now().Unix()))
 			lastScrapeDurationSecondsMetric.Set(duration)
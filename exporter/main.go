@@ -48,6 +48,22 @@ var (
 		"web.tls.key_file", "Path to a file that contains the TLS private key (PEM format) ($BOSHUPDATE_EXPORTER_WEB_TLS_KEYFILE)",
 	).Envar("BOSHUPDATE_EXPORTER_WEB_TLS_KEYFILE").ExistingFile()
 
+	fromSnapshot = kingpin.Flag(
+		"from-snapshot", "Serve metrics from given snapshot file instead of querying GitHub and bosh director ($BOSHUPDATE_EXPORTER_FROM_SNAPSHOT)",
+	).Envar("BOSHUPDATE_EXPORTER_FROM_SNAPSHOT").String()
+
+	filterManifest = kingpin.Flag(
+		"filter.manifest", "Only export manifest releases whose name or owner/repo matches given regexp ($BOSHUPDATE_EXPORTER_FILTER_MANIFEST)",
+	).Envar("BOSHUPDATE_EXPORTER_FILTER_MANIFEST").Regexp()
+
+	filterGeneric = kingpin.Flag(
+		"filter.generic", "Only export generic releases whose name or owner/repo matches given regexp ($BOSHUPDATE_EXPORTER_FILTER_GENERIC)",
+	).Envar("BOSHUPDATE_EXPORTER_FILTER_GENERIC").Regexp()
+
+	filterDeployment = kingpin.Flag(
+		"filter.deployment", "Only export bosh deployments whose name matches given regexp ($BOSHUPDATE_EXPORTER_FILTER_DEPLOYMENT)",
+	).Envar("BOSHUPDATE_EXPORTER_FILTER_DEPLOYMENT").Regexp()
+
 	logLevel = kingpin.Flag(
 		"log.level", "Only log messages with the given severity or above. Valid levels: [debug, info, warn, error, fatal]",
 	).Default("info").String()
@@ -76,11 +92,10 @@ func (h *basicAuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.handler(w, r)
 }
 
-func prometheusHandler() http.Handler {
-	handler := promhttp.Handler()
+func withAuth(handler http.Handler) http.Handler {
 	if *authUsername != "" && *authPassword != "" {
 		handler = &basicAuthHandler{
-			handler:  promhttp.Handler().ServeHTTP,
+			handler:  handler.ServeHTTP,
 			username: *authUsername,
 			password: *authPassword,
 		}
@@ -88,6 +103,10 @@ func prometheusHandler() http.Handler {
 	return handler
 }
 
+func prometheusHandler() http.Handler {
+	return withAuth(promhttp.Handler())
+}
+
 func main() {
 	kingpin.Version(version.Print("boshupdate_exporter"))
 	kingpin.HelpFlag.Short('h')
@@ -108,18 +127,39 @@ func main() {
 	log.Infoln("Starting boshupdate_exporter", version.Info())
 	log.Infoln("Build context", version.BuildContext())
 
-	config := boshupdate.NewConfig(*configFile)
+	var config *boshupdate.Config
+	if *fromSnapshot != "" {
+		// snapshot may be read on another machine, lacking credential files
+		config = boshupdate.NewOfflineConfig(*configFile)
+	} else {
+		config = boshupdate.NewConfig(*configFile)
+	}
 	manager, err := boshupdate.NewManager(*config)
 	if err != nil {
 		log.Errorln(err)
 		os.Exit(1)
 	}
+	if *fromSnapshot != "" {
+		snapshot, err := boshupdate.LoadSnapshot(*fromSnapshot)
+		if err != nil {
+			log.Errorln(err)
+			os.Exit(1)
+		}
+		log.Infof("serving data from snapshot '%s'", *fromSnapshot)
+		manager.UseSnapshot(snapshot)
+	}
 
-	initMetricsReporter(*metricsNamespace, *metricsEnvironment)
+	initMetricsReporter(*metricsNamespace, *metricsEnvironment, config.Bosh.Labels.Names())
 
 	interval, _ := time.ParseDuration(config.Github.UpdateInterval)
-	startUpdate(manager, interval)
+	filter := boshupdate.Filter{
+		Manifest:   *filterManifest,
+		Generic:    *filterGeneric,
+		Deployment: *filterDeployment,
+	}
+	startUpdate(manager, config.Bosh, interval, filter)
 	http.Handle(*metricsPath, prometheusHandler())
+	http.Handle("/api/", apiHandler())
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		_, err := w.Write([]byte(`<html>
              <head><title>Boshupdate Exporter</title></head>